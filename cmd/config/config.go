@@ -5,23 +5,96 @@ import (
 )
 
 type Cnf struct {
-	ApiServer ApiServer
-	YandexAsr YandexAsr
+	ApiServer  ApiServer
+	YandexAsr  YandexAsr
+	VoskAsr    VoskAsr
+	WhisperAsr WhisperAsr
+	Queue      Queue
+	S3Storage  S3Storage
+	Upload     Upload
+	Quota      Quota
+	Pricing    map[string]Pricing
 }
 
 type YandexAsr struct {
 	YandexFolderId  string
 	YandexKey       string
 	YandexAsrUri    string
-	Format          string
 	SampleRateHertz string
 }
 
+type VoskAsr struct {
+	VoskAsrUri      string
+	SampleRateHertz int
+	ChunkSize       int
+}
+
+type WhisperAsr struct {
+	WhisperAsrUri string
+	WhisperKey    string
+	Model         string
+	Language      string
+}
+
+type Queue struct {
+	Workers      int
+	PollInterval uint
+	Instance     string
+	Lease        uint
+	Retry        map[string]RetryPolicy
+}
+
+type RetryPolicy struct {
+	MaxAttempts          int
+	InitialBackoff       uint
+	MaxBackoff           uint
+	Multiplier           float64
+	RetryableStatusCodes []int
+	RetryOnTimeout       bool
+	RetryOnNetworkError  bool
+}
+
+// Quota лимиты аудио-минут за период, 0 — без ограничения
+type Quota struct {
+	Period      string
+	UserMinutes float64
+	ASR         map[string]ASRQuota
+}
+
+type ASRQuota struct {
+	UserMinutes  float64
+	TotalMinutes float64
+}
+
+// Pricing тариф ASR: цена Price за каждые Unit секунд аудио
+type Pricing struct {
+	Currency string
+	Unit     uint
+	Price    float64
+	Rounding string
+	MinUnits uint
+}
+
+type S3Storage struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+}
+
+type Upload struct {
+	MaxSize     int64
+	ReadTimeout uint
+}
+
 type ApiServer struct {
 	SecretKeyForAccessToken     string
 	SecretKeyForRefreshToken    string
 	AccessTokenExpiresAt        uint
 	RefreshTokenExpiresAt       uint
+	RefreshTokenReuseGrace      uint
 	SecretKeyForHashingPassword string
 }
 
@@ -30,6 +103,7 @@ type ConfigData struct {
 	ConfigASR       string
 	DatabaseDSN     string
 	PathFileStorage string
+	StorageType     string
 }
 
 func NewConfig() *ConfigData {
@@ -0,0 +1,3 @@
+ALTER TABLE users
+		ADD COLUMN role TEXT NOT NULL DEFAULT 'user',
+		ADD COLUMN disabled_at TIMESTAMP;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS audiofiles_workspace_id_content_hash_idx;
+DELETE FROM quality_control WHERE file_id IN (SELECT file_id FROM audiofiles WHERE workspace_id IS NOT NULL);
+DELETE FROM result_asr WHERE uuid IN (SELECT uuid FROM asr WHERE file_id IN (SELECT file_id FROM audiofiles WHERE workspace_id IS NOT NULL));
+DELETE FROM asr WHERE file_id IN (SELECT file_id FROM audiofiles WHERE workspace_id IS NOT NULL);
+DELETE FROM audiofiles WHERE workspace_id IS NOT NULL;
+DROP INDEX IF EXISTS audiofiles_user_id_content_hash_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS audiofiles_user_id_content_hash_idx ON audiofiles (user_id, content_hash) WHERE content_hash <> '';
+ALTER TABLE audiofiles DROP COLUMN IF EXISTS workspace_id;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
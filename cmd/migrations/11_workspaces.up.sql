@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+		uuid TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT now(),
+		FOREIGN KEY (created_by) REFERENCES users(uuid)
+	  );
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+		workspace_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		added_at TIMESTAMP NOT NULL DEFAULT now(),
+		PRIMARY KEY (workspace_id, user_id),
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(uuid),
+		FOREIGN KEY (user_id) REFERENCES users(uuid)
+	  );
+
+CREATE INDEX IF NOT EXISTS workspace_members_user_id_idx ON workspace_members (user_id);
+
+ALTER TABLE audiofiles
+		ADD COLUMN workspace_id TEXT REFERENCES workspaces(uuid);
+
+DROP INDEX IF EXISTS audiofiles_user_id_content_hash_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS audiofiles_user_id_content_hash_idx ON audiofiles (user_id, content_hash) WHERE content_hash <> '' AND workspace_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS audiofiles_workspace_id_content_hash_idx ON audiofiles (workspace_id, content_hash) WHERE content_hash <> '' AND workspace_id IS NOT NULL;
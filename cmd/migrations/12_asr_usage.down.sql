@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS asr_asr_created_at_idx;
+DROP INDEX IF EXISTS asr_user_id_created_at_idx;
+
+ALTER TABLE asr
+		DROP COLUMN IF EXISTS audio_seconds,
+		DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,8 @@
+ALTER TABLE asr
+		ADD COLUMN user_id TEXT NOT NULL DEFAULT '',
+		ADD COLUMN audio_seconds DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE asr a SET user_id = f.user_id, audio_seconds = f.duration FROM audiofiles f WHERE a.file_id = f.file_id;
+
+CREATE INDEX IF NOT EXISTS asr_user_id_created_at_idx ON asr (user_id, created_at);
+CREATE INDEX IF NOT EXISTS asr_asr_created_at_idx ON asr (asr, created_at);
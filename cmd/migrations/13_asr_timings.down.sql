@@ -0,0 +1,5 @@
+ALTER TABLE asr
+		DROP COLUMN IF EXISTS upstream_seconds,
+		DROP COLUMN IF EXISTS finished_at,
+		DROP COLUMN IF EXISTS started_at,
+		DROP COLUMN IF EXISTS queued_at;
@@ -0,0 +1,7 @@
+ALTER TABLE asr
+		ADD COLUMN queued_at TIMESTAMP,
+		ADD COLUMN started_at TIMESTAMP,
+		ADD COLUMN finished_at TIMESTAMP,
+		ADD COLUMN upstream_seconds DOUBLE PRECISION;
+
+UPDATE asr SET queued_at = created_at;
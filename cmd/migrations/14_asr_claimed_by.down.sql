@@ -0,0 +1,2 @@
+ALTER TABLE asr
+		DROP COLUMN IF EXISTS claimed_by;
@@ -0,0 +1,2 @@
+ALTER TABLE asr
+		ADD COLUMN claimed_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE asr
+		ADD COLUMN claim_id TEXT NOT NULL DEFAULT '';
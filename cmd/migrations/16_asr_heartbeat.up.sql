@@ -0,0 +1,4 @@
+ALTER TABLE asr
+		ADD COLUMN heartbeat_at TIMESTAMP;
+
+UPDATE asr SET heartbeat_at = started_at WHERE status = 'PROCESSING';
@@ -0,0 +1,3 @@
+ALTER TABLE result_asr
+		DROP COLUMN words,
+		DROP COLUMN confidence;
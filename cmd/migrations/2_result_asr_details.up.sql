@@ -0,0 +1,3 @@
+ALTER TABLE result_asr
+		ADD COLUMN confidence REAL NOT NULL DEFAULT 0,
+		ADD COLUMN words JSONB NOT NULL DEFAULT '[]';
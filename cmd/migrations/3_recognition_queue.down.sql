@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS asr_status_created_at_idx;
+
+ALTER TABLE asr
+		DROP COLUMN updated_at,
+		DROP COLUMN created_at,
+		DROP COLUMN language;
@@ -0,0 +1,6 @@
+ALTER TABLE asr
+		ADD COLUMN language TEXT NOT NULL DEFAULT '',
+		ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT now(),
+		ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS asr_status_created_at_idx ON asr (status, created_at);
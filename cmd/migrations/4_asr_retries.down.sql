@@ -0,0 +1,4 @@
+ALTER TABLE asr
+		DROP COLUMN next_attempt_at,
+		DROP COLUMN last_error,
+		DROP COLUMN attempts;
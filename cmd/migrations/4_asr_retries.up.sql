@@ -0,0 +1,4 @@
+ALTER TABLE asr
+		ADD COLUMN attempts INT NOT NULL DEFAULT 0,
+		ADD COLUMN last_error TEXT NOT NULL DEFAULT '',
+		ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT now();
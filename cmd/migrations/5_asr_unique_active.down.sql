@@ -0,0 +1 @@
+DROP INDEX IF EXISTS asr_file_id_asr_active_idx;
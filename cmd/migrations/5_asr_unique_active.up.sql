@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS asr_file_id_asr_active_idx ON asr (file_id, asr) WHERE status NOT IN ('INVALID', 'FAILED');
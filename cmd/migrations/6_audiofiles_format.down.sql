@@ -0,0 +1,6 @@
+ALTER TABLE audiofiles
+		DROP COLUMN duration,
+		DROP COLUMN channels,
+		DROP COLUMN bits_per_sample,
+		DROP COLUMN sample_rate_hertz,
+		DROP COLUMN codec;
@@ -0,0 +1,6 @@
+ALTER TABLE audiofiles
+		ADD COLUMN codec TEXT NOT NULL DEFAULT '',
+		ADD COLUMN sample_rate_hertz INT NOT NULL DEFAULT 0,
+		ADD COLUMN bits_per_sample INT NOT NULL DEFAULT 0,
+		ADD COLUMN channels INT NOT NULL DEFAULT 0,
+		ADD COLUMN duration DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS audiofiles_user_id_content_hash_idx;
+
+ALTER TABLE audiofiles
+		DROP COLUMN storage_key,
+		DROP COLUMN content_hash;
@@ -0,0 +1,7 @@
+ALTER TABLE audiofiles
+		ADD COLUMN content_hash TEXT NOT NULL DEFAULT '',
+		ADD COLUMN storage_key TEXT NOT NULL DEFAULT '';
+
+UPDATE audiofiles SET storage_key = file_name;
+
+CREATE UNIQUE INDEX IF NOT EXISTS audiofiles_user_id_content_hash_idx ON audiofiles (user_id, content_hash) WHERE content_hash <> '';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+		uuid TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL,
+		scopes TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT now(),
+		last_used_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		UNIQUE (key_hash),
+		FOREIGN KEY (user_id) REFERENCES users(uuid)
+	  );
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
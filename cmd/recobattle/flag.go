@@ -13,6 +13,7 @@ func parseFlags(cfg *config.ConfigData) {
 	flag.StringVar(&cfg.DatabaseDSN, "d", "", "adress connect database")
 	flag.StringVar(&cfg.ConfigASR, "c", "../config/config.toml", "path config")
 	flag.StringVar(&cfg.PathFileStorage, "s", "", "path file storage")
+	flag.StringVar(&cfg.StorageType, "st", "local", "file storage type: local or s3")
 
 	flag.Parse()
 
@@ -31,4 +32,8 @@ func parseFlags(cfg *config.ConfigData) {
 	if envPathFileStorage := os.Getenv("PATH_FILE_STORAGE"); envPathFileStorage != "" {
 		cfg.PathFileStorage = envPathFileStorage
 	}
+
+	if envStorageType := os.Getenv("STORAGE_TYPE"); envStorageType != "" {
+		cfg.StorageType = envStorageType
+	}
 }
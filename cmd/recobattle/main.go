@@ -7,22 +7,32 @@ import (
 	"os/signal"
 
 	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/adminapp"
 	"github.com/RecoBattle/internal/app/asr"
+	"github.com/RecoBattle/internal/app/asr/vosk"
+	"github.com/RecoBattle/internal/app/asr/whisper"
 	yandexspeachkit "github.com/RecoBattle/internal/app/asr/yandexSpeachKit"
 	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/pricing"
 	"github.com/RecoBattle/internal/app/qualitycontrolapp"
 	"github.com/RecoBattle/internal/app/userapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/RecoBattle/internal/controller/handler"
+	"github.com/RecoBattle/internal/controller/handler/adminhandler"
 	"github.com/RecoBattle/internal/controller/handler/audiofileshandler"
 	"github.com/RecoBattle/internal/controller/handler/qualitycontrolhandler"
 	"github.com/RecoBattle/internal/controller/handler/userhandler"
+	"github.com/RecoBattle/internal/controller/handler/workspacehandler"
 	"github.com/RecoBattle/internal/controller/router"
 	"github.com/RecoBattle/internal/controller/server"
 	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/admindb"
 	"github.com/RecoBattle/internal/database/audiofilesdb"
 	"github.com/RecoBattle/internal/database/qualitycontroldb"
 	"github.com/RecoBattle/internal/database/userdb"
+	"github.com/RecoBattle/internal/database/workspacedb"
 	"github.com/RecoBattle/internal/logger"
+	"github.com/RecoBattle/internal/storage"
 )
 
 func main() {
@@ -54,15 +64,37 @@ func main() {
 	yandexASR := yandexspeachkit.NewYandexASRStore(cnf.YandexAsr)
 	asrRegistry.AddService("yandexSpeachKit", yandexASR)
 
+	voskASR := vosk.NewVoskASRStore(cnf.VoskAsr)
+	asrRegistry.AddService("vosk", voskASR)
+
+	whisperASR := whisper.NewWhisperASRStore(cnf.WhisperAsr)
+	asrRegistry.AddService("whisper", whisperASR)
+
+	fileStorage, err := storage.NewStorage(cfg.StorageType, cfg.PathFileStorage, cnf.S3Storage)
+	if err != nil {
+		log.Fatalf("error in init file storage. error: %v", err)
+	}
+
+	prices, err := pricing.New(cnf.Pricing)
+	if err != nil {
+		log.Fatalf("error in ASR pricing config. error: %v", err)
+	}
+
 	//Init storage and services
 	userStore := userdb.NewUserStore(db)
 	userApp := userapp.NewUser(userStore, cnf.ApiServer)
 
 	audiofileStore := audiofilesdb.NewAudioFileStore(db)
-	audiofilesApp := audiofilesapp.NewAudioFile(audiofileStore)
+	audiofilesApp := audiofilesapp.NewAudioFile(audiofileStore, &asrRegistry, fileStorage, cnf.Queue, cnf.Quota, prices)
 
 	qcStore := qualitycontroldb.NewQCStore(db)
-	qcApp := qualitycontrolapp.NewQualityControl(qcStore)
+	qcApp := qualitycontrolapp.NewQualityControl(qcStore, prices)
+
+	adminStore := admindb.NewAdminStore(db)
+	adminApp := adminapp.NewAdmin(adminStore)
+
+	workspaceStore := workspacedb.NewWorkspaceStore(db)
+	workspaceApp := workspaceapp.NewWorkspace(workspaceStore)
 
 	//Add Actions to Handlers to slice
 	var registeredHandlers []handler.Handler
@@ -70,17 +102,30 @@ func main() {
 	userHandler := userhandler.NewUserHandler(userApp)
 	registeredHandlers = append(registeredHandlers, userHandler)
 
-	audiofilesHandler := audiofileshandler.NewAudioFilesHandler(audiofilesApp, &asrRegistry, cfg.PathFileStorage)
+	audiofilesHandler := audiofileshandler.NewAudioFilesHandler(audiofilesApp, &asrRegistry, cnf.Upload)
 	registeredHandlers = append(registeredHandlers, audiofilesHandler)
 
 	qcHandler := qualitycontrolhandler.NewQCHandler(qcApp)
 	registeredHandlers = append(registeredHandlers, qcHandler)
 
+	adminHandler := adminhandler.NewAdminHandler(adminApp)
+	registeredHandlers = append(registeredHandlers, adminHandler)
+
+	workspaceHandler := workspacehandler.NewWorkspaceHandler(workspaceApp)
+	registeredHandlers = append(registeredHandlers, workspaceHandler)
+
 	appRouter := router.NewRouter(cnf.ApiServer, registeredHandlers, userApp)
 	appServer := server.NewServer(cfg.RunAddr, appRouter.Echo)
 
+	workersDone := make(chan struct{})
+	go func() {
+		audiofilesApp.RunWorkers(ctx)
+		close(workersDone)
+	}()
+
 	go appServer.Start()
 
 	<-ctx.Done()
 	appServer.Stop(ctx)
+	<-workersDone
 }
@@ -0,0 +1,108 @@
+package adminapp
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/userapp"
+)
+
+var (
+	// ErrInvalidStatus статус, в который администратор не может перевести задание
+	ErrInvalidStatus = errors.New("invalid job status")
+	// ErrInvalidRole неизвестная роль
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrSelfModification администратор не может отключить себя или снять с себя роль
+	ErrSelfModification = errors.New("administrator cannot disable or demote themselves")
+)
+
+// JobStatuses статусы, в которые администратор может перевести задание: NEW возвращает его в очередь,
+// FAILED и INVALID снимают зависшее задание
+var JobStatuses = []string{audiofilesapp.StatusNEW, audiofilesapp.StatusFAILED, audiofilesapp.StatusINVALID}
+
+// Roles роли, которые можно назначить пользователю
+var Roles = []string{userapp.RoleUser, userapp.RoleAdmin}
+
+type User struct {
+	UUID     string `json:"id"`
+	Login    string `json:"login"`
+	Role     string `json:"role"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Job задание на распознавание вместе с владельцем файла
+type Job struct {
+	audiofilesapp.AudioFile
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+}
+
+// JobFilter отбор заданий; пустые поля не ограничивают выборку
+type JobFilter struct {
+	UserID string
+	ASR    string
+	Status string
+	Limit  uint64
+	Offset uint64
+}
+
+type AdminStore interface {
+	GetUsers(ctx context.Context) ([]User, error)
+	SetUserDisabled(ctx context.Context, userID string, disabled bool) error
+	SetUserRole(ctx context.Context, userID, role string) error
+	GetJobs(ctx context.Context, filter JobFilter) ([]Job, error)
+	SetJobStatus(ctx context.Context, jobUUID, status string) error
+}
+
+type Admin struct {
+	adminStore AdminStore
+}
+
+func NewAdmin(adminStore AdminStore) *Admin {
+	return &Admin{adminStore: adminStore}
+}
+
+func (a *Admin) GetUsers(ctx context.Context) ([]User, error) {
+	return a.adminStore.GetUsers(ctx)
+}
+
+// SetUserDisabled отключает или включает учётную запись. У отключённого пользователя не работают вход, обмен refresh-токена
+// и API-ключи, уже выданный access-токен действует до истечения срока
+func (a *Admin) SetUserDisabled(ctx context.Context, adminID, userID string, disabled bool) error {
+
+	if disabled && adminID == userID {
+		return ErrSelfModification
+	}
+
+	return a.adminStore.SetUserDisabled(ctx, userID, disabled)
+}
+
+// SetUserRole меняет роль пользователя, новая роль попадает в токены при следующем входе или обмене refresh-токена
+func (a *Admin) SetUserRole(ctx context.Context, adminID, userID, role string) error {
+
+	if !slices.Contains(Roles, role) {
+		return ErrInvalidRole
+	}
+
+	if adminID == userID && role != userapp.RoleAdmin {
+		return ErrSelfModification
+	}
+
+	return a.adminStore.SetUserRole(ctx, userID, role)
+}
+
+func (a *Admin) GetJobs(ctx context.Context, filter JobFilter) ([]Job, error) {
+	return a.adminStore.GetJobs(ctx, filter)
+}
+
+// SetJobStatus переводит задание любого пользователя в новый статус. Задание в NEW подхватят воркеры при следующем опросе очереди
+func (a *Admin) SetJobStatus(ctx context.Context, jobUUID, status string) error {
+
+	if !slices.Contains(JobStatuses, status) {
+		return ErrInvalidStatus
+	}
+
+	return a.adminStore.SetJobStatus(ctx, jobUUID, status)
+}
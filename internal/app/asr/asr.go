@@ -1,13 +1,83 @@
 package asr
 
 import (
+	"context"
+	"sort"
 	"sync"
 
 	"github.com/labstack/gommon/log"
 )
 
+const (
+	FormatWAV  = "wav"
+	FormatLPCM = "lpcm"
+)
+
+// Audio описывает аудио, которое передаётся в сервис ASR
+type Audio struct {
+	Data            []byte
+	Format          string
+	SampleRateHertz int
+	BitsPerSample   int
+	Channels        int
+}
+
+// Duration возвращает длительность в секундах для PCM без заголовка
+func (a Audio) Duration() float32 {
+
+	if a.Format != FormatLPCM || a.SampleRateHertz <= 0 {
+		return 0
+	}
+
+	bitsPerSample := a.BitsPerSample
+	if bitsPerSample <= 0 {
+		bitsPerSample = 16
+	}
+
+	channels := a.Channels
+	if channels <= 0 {
+		channels = 1
+	}
+
+	return float32(len(a.Data)) / float32(a.SampleRateHertz*channels*bitsPerSample/8)
+}
+
+// Requirements описывает формат аудио, который принимает сервис ASR. Нулевое значение поля означает «как в исходном файле»
+type Requirements struct {
+	Format          string
+	SampleRateHertz int
+	BitsPerSample   int
+	Channels        int
+}
+
+type Options struct {
+	Language string
+}
+
+type Word struct {
+	Text       string  `json:"word"`
+	StartTime  float32 `json:"startTime"`
+	EndTime    float32 `json:"endTime"`
+	Confidence float32 `json:"confidence"`
+}
+
+type Segment struct {
+	ChannelTag string
+	Text       string
+	StartTime  float32
+	EndTime    float32
+	Confidence float32
+	Words      []Word
+}
+
+type Transcript struct {
+	Text     string
+	Segments []Segment
+}
+
 type ASR interface {
-	TextFromASRModel(data []byte) (string, error)
+	Requirements() Requirements
+	Recognize(ctx context.Context, audio Audio, opts Options) (*Transcript, error)
 }
 
 type ASRRegistry struct {
@@ -35,3 +105,16 @@ func (asrRegistry *ASRRegistry) GetService(name string) (ASR, bool) {
 	service, ok := asrRegistry.Services[name]
 	return service, ok
 }
+
+func (asrRegistry *ASRRegistry) Names() []string {
+	asrRegistry.RLock()
+	defer asrRegistry.RUnlock()
+
+	names := make([]string, 0, len(asrRegistry.Services))
+	for name := range asrRegistry.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,20 @@
+package asr
+
+import (
+	"fmt"
+)
+
+// StatusError возвращается, когда сервис ASR ответил кодом, отличным от успешного
+type StatusError struct {
+	Service    string
+	StatusCode int
+	Message    string
+}
+
+func NewStatusError(service string, statusCode int, message string) error {
+	return &StatusError{Service: service, StatusCode: statusCode, Message: message}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v asr: unexpected status %d: %v", e.Service, e.StatusCode, e.Message)
+}
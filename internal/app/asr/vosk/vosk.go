@@ -0,0 +1,219 @@
+package vosk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/labstack/gommon/log"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultChunkSize = 8000
+	messageTimeout   = 30 * time.Second
+)
+
+type Word struct {
+	Conf  float64 `json:"conf"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Word  string  `json:"word"`
+}
+
+type Result struct {
+	Partial string `json:"partial"`
+	Text    string `json:"text"`
+	Words   []Word `json:"result"`
+}
+
+type Transcript struct {
+	Partials []string
+	Results  []Result
+}
+
+type ServiceASRVosk struct {
+	cnf    config.VoskAsr
+	dialer *net.Dialer
+}
+
+var _ asr.ASR = &ServiceASRVosk{}
+
+func NewVoskASRStore(cnf config.VoskAsr) *ServiceASRVosk {
+	return &ServiceASRVosk{
+		cnf: cnf,
+		dialer: &net.Dialer{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Requirements vosk-server принимает моно PCM 16 бит, частота должна совпадать с частотой модели
+func (ct ServiceASRVosk) Requirements() asr.Requirements {
+	return asr.Requirements{
+		Format:          asr.FormatLPCM,
+		SampleRateHertz: ct.cnf.SampleRateHertz,
+		BitsPerSample:   16,
+		Channels:        1,
+	}
+}
+
+func (ct ServiceASRVosk) Recognize(ctx context.Context, audio asr.Audio, _ asr.Options) (*asr.Transcript, error) {
+
+	sampleRateHertz := ct.cnf.SampleRateHertz
+	if audio.SampleRateHertz > 0 {
+		sampleRateHertz = audio.SampleRateHertz
+	}
+
+	transcript, err := ct.stream(ctx, audio.Data, sampleRateHertz)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	result := &asr.Transcript{}
+	texts := make([]string, 0, len(transcript.Results))
+
+	for _, res := range transcript.Results {
+		if res.Text == "" {
+			continue
+		}
+
+		texts = append(texts, res.Text)
+		result.Segments = append(result.Segments, segment(res))
+	}
+
+	result.Text = strings.Join(texts, " ")
+
+	return result, nil
+}
+
+func segment(res Result) asr.Segment {
+
+	seg := asr.Segment{ChannelTag: "1", Text: res.Text}
+
+	if len(res.Words) == 0 {
+		return seg
+	}
+
+	var conf float64
+	for _, w := range res.Words {
+		seg.Words = append(seg.Words, asr.Word{
+			Text:       w.Word,
+			StartTime:  float32(w.Start),
+			EndTime:    float32(w.End),
+			Confidence: float32(w.Conf),
+		})
+		conf += w.Conf
+	}
+
+	seg.StartTime = float32(res.Words[0].Start)
+	seg.EndTime = float32(res.Words[len(res.Words)-1].End)
+	seg.Confidence = float32(conf / float64(len(res.Words)))
+
+	return seg
+}
+
+// stream отправляет PCM на vosk-server по websocket и собирает промежуточные и финальные результаты
+func (ct ServiceASRVosk) stream(ctx context.Context, data []byte, sampleRateHertz int) (*Transcript, error) {
+
+	wsConfig, err := websocket.NewConfig(ct.cnf.VoskAsrUri, "http://localhost/")
+	if err != nil {
+		log.Errorf("error in creating config for Vosk ASR. error: %v", err)
+		return nil, err
+	}
+	wsConfig.Dialer = ct.dialer
+
+	ws, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		log.Errorf("error in connecting to Vosk ASR. error: %v", err)
+		return nil, err
+	}
+
+	defer ws.Close()
+
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+
+	var transcript Transcript
+
+	configMessage := fmt.Sprintf(`{"config": {"sample_rate": %d, "words": 1}}`, sampleRateHertz)
+	if err = ct.send(ws, configMessage); err != nil {
+		log.Errorf("error in sending config to Vosk ASR. error: %v", err)
+		return nil, err
+	}
+
+	chunkSize := ct.cnf.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	for start := 0; start < len(data); start += chunkSize {
+
+		end := min(start+chunkSize, len(data))
+
+		if err = ct.send(ws, data[start:end]); err != nil {
+			log.Errorf("error in sending audio to Vosk ASR. error: %v", err)
+			return nil, err
+		}
+
+		if err = ct.receive(ws, &transcript); err != nil {
+			log.Errorf("error in reading Vosk ASR response. error: %v", err)
+			return nil, err
+		}
+	}
+
+	if err = ct.send(ws, `{"eof" : 1}`); err != nil {
+		log.Errorf("error in sending eof to Vosk ASR. error: %v", err)
+		return nil, err
+	}
+
+	if err = ct.receive(ws, &transcript); err != nil {
+		log.Errorf("error in reading Vosk ASR final response. error: %v", err)
+		return nil, err
+	}
+
+	return &transcript, nil
+}
+
+func (ct ServiceASRVosk) send(ws *websocket.Conn, message interface{}) error {
+
+	if err := ws.SetWriteDeadline(time.Now().Add(messageTimeout)); err != nil {
+		return err
+	}
+
+	return websocket.Message.Send(ws, message)
+}
+
+func (ct ServiceASRVosk) receive(ws *websocket.Conn, transcript *Transcript) error {
+
+	if err := ws.SetReadDeadline(time.Now().Add(messageTimeout)); err != nil {
+		return err
+	}
+
+	var message []byte
+	if err := websocket.Message.Receive(ws, &message); err != nil {
+		return err
+	}
+
+	var result Result
+	if err := json.Unmarshal(message, &result); err != nil {
+		return err
+	}
+
+	switch {
+	case result.Partial != "":
+		transcript.Partials = append(transcript.Partials, result.Partial)
+	case result.Text != "" || len(result.Words) > 0:
+		transcript.Results = append(transcript.Results, result)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package vosk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// voskStandIn повторяет протокол vosk-server: config без ответа, ответ на каждый чанк, финальный результат на eof
+func voskStandIn(t *testing.T, received *bytes.Buffer) *httptest.Server {
+
+	handler := websocket.Handler(func(ws *websocket.Conn) {
+
+		chunks := 0
+
+		for {
+			var message []byte
+			if err := websocket.Message.Receive(ws, &message); err != nil {
+				return
+			}
+
+			switch {
+			case strings.Contains(string(message), "config"):
+				var cfg map[string]map[string]int
+				require.NoError(t, json.Unmarshal(message, &cfg))
+				assert.Equal(t, 8000, cfg["config"]["sample_rate"])
+				continue
+			case string(message) == `{"eof" : 1}`:
+				websocket.Message.Send(ws, `{"result": [{"conf": 0.9, "start": 1.2, "end": 1.5, "word": "здравствуйте"}], "text": "здравствуйте"}`)
+				return
+			}
+
+			received.Write(message)
+			chunks++
+
+			if chunks == 1 {
+				websocket.Message.Send(ws, `{"partial": "добрый"}`)
+				continue
+			}
+
+			websocket.Message.Send(ws, `{"result": [{"conf": 1.0, "start": 0.3, "end": 0.6, "word": "добрый"}, {"conf": 0.8, "start": 0.6, "end": 0.9, "word": "день"}], "text": "добрый день"}`)
+		}
+	})
+
+	return httptest.NewServer(handler)
+}
+
+func TestServiceASRVosk_Recognize(t *testing.T) {
+
+	var received bytes.Buffer
+
+	server := voskStandIn(t, &received)
+	defer server.Close()
+
+	pcm := bytes.Repeat([]byte{1, 2}, 6)
+
+	service := NewVoskASRStore(config.VoskAsr{
+		VoskAsrUri:      "ws" + strings.TrimPrefix(server.URL, "http"),
+		SampleRateHertz: 16000,
+		ChunkSize:       8,
+	})
+
+	transcript, err := service.stream(context.Background(), pcm, 8000)
+	require.NoError(t, err)
+
+	assert.Equal(t, pcm, received.Bytes())
+	assert.Equal(t, []string{"добрый"}, transcript.Partials)
+	require.Len(t, transcript.Results, 2)
+	assert.Equal(t, Word{Conf: 0.8, Start: 0.6, End: 0.9, Word: "день"}, transcript.Results[0].Words[1])
+	assert.Equal(t, "здравствуйте", transcript.Results[1].Text)
+
+	result, err := service.Recognize(context.Background(), asr.Audio{Data: pcm, Format: asr.FormatLPCM, SampleRateHertz: 8000}, asr.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "добрый день здравствуйте", result.Text)
+	require.Len(t, result.Segments, 2)
+	assert.Equal(t, asr.Segment{
+		ChannelTag: "1",
+		Text:       "добрый день",
+		StartTime:  0.3,
+		EndTime:    0.9,
+		Confidence: 0.9,
+		Words: []asr.Word{
+			{Text: "добрый", StartTime: 0.3, EndTime: 0.6, Confidence: 1},
+			{Text: "день", StartTime: 0.6, EndTime: 0.9, Confidence: 0.8},
+		},
+	}, result.Segments[0])
+}
+
+func TestServiceASRVosk_ConnectionRefused(t *testing.T) {
+
+	server := voskStandIn(t, &bytes.Buffer{})
+	uri := "ws" + strings.TrimPrefix(server.URL, "http")
+	server.Close()
+
+	service := NewVoskASRStore(config.VoskAsr{VoskAsrUri: uri, SampleRateHertz: 8000})
+
+	_, err := service.Recognize(context.Background(), asr.Audio{Data: []byte{0, 0}, Format: asr.FormatLPCM}, asr.Options{})
+	assert.Error(t, err)
+}
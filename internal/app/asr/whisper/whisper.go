@@ -0,0 +1,200 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/labstack/gommon/log"
+)
+
+type Segment struct {
+	ID           int     `json:"id"`
+	Start        float32 `json:"start"`
+	End          float32 `json:"end"`
+	Text         string  `json:"text"`
+	AvgLogprob   float32 `json:"avg_logprob"`
+	NoSpeechProb float32 `json:"no_speech_prob"`
+}
+
+type Word struct {
+	Word        string  `json:"word"`
+	Start       float32 `json:"start"`
+	End         float32 `json:"end"`
+	Probability float32 `json:"probability"`
+}
+
+type Response struct {
+	Language string    `json:"language"`
+	Duration float32   `json:"duration"`
+	Text     string    `json:"text"`
+	Segments []Segment `json:"segments"`
+	Words    []Word    `json:"words"`
+}
+
+type ServiceASRWhisper struct {
+	cnf    config.WhisperAsr
+	client *http.Client
+}
+
+var _ asr.ASR = &ServiceASRWhisper{}
+
+func NewWhisperASRStore(cnf config.WhisperAsr) *ServiceASRWhisper {
+	return &ServiceASRWhisper{
+		cnf: cnf,
+		client: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+	}
+}
+
+// Requirements whisper работает с моно wav 16 кГц, остальное сервер всё равно пересэмплирует сам
+func (ct ServiceASRWhisper) Requirements() asr.Requirements {
+	return asr.Requirements{
+		Format:          asr.FormatWAV,
+		SampleRateHertz: 16000,
+		BitsPerSample:   16,
+		Channels:        1,
+	}
+}
+
+func (ct ServiceASRWhisper) Recognize(ctx context.Context, audio asr.Audio, opts asr.Options) (*asr.Transcript, error) {
+
+	language := opts.Language
+	if language == "" {
+		language = ct.cnf.Language
+	}
+
+	result, err := ct.transcribe(ctx, audio.Data, language)
+	if err != nil {
+		return nil, err
+	}
+
+	transcript := &asr.Transcript{Text: strings.TrimSpace(result.Text)}
+
+	for _, s := range result.Segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+
+		segment := asr.Segment{
+			ChannelTag: "1",
+			Text:       text,
+			StartTime:  s.Start,
+			EndTime:    s.End,
+			Confidence: float32(math.Exp(float64(s.AvgLogprob))),
+		}
+
+		for _, w := range result.Words {
+			if w.Start >= s.Start && w.End <= s.End {
+				segment.Words = append(segment.Words, asr.Word{
+					Text:       strings.TrimSpace(w.Word),
+					StartTime:  w.Start,
+					EndTime:    w.End,
+					Confidence: w.Probability,
+				})
+			}
+		}
+
+		transcript.Segments = append(transcript.Segments, segment)
+	}
+
+	if len(transcript.Segments) == 0 && transcript.Text != "" {
+		transcript.Segments = append(transcript.Segments, asr.Segment{ChannelTag: "1", Text: transcript.Text, EndTime: result.Duration})
+	}
+
+	return transcript, nil
+}
+
+// transcribe отправляет wav-файл multipart-запросом в whisper.cpp server (/inference) или OpenAI-совместимый /v1/audio/transcriptions
+func (ct ServiceASRWhisper) transcribe(ctx context.Context, data []byte, language string) (*Response, error) {
+
+	var result Response
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		log.Errorf("error in creating multipart body for Whisper ASR. error: %v", err)
+		return nil, err
+	}
+
+	if _, err = part.Write(data); err != nil {
+		log.Errorf("error in writing audio to multipart body for Whisper ASR. error: %v", err)
+		return nil, err
+	}
+
+	fields := map[string]string{
+		"response_format": "verbose_json",
+		"model":           ct.cnf.Model,
+		"language":        language,
+	}
+
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err = writer.WriteField(name, value); err != nil {
+			log.Errorf("error in writing field %v for Whisper ASR. error: %v", name, err)
+			return nil, err
+		}
+	}
+
+	for _, granularity := range []string{"segment", "word"} {
+		if err = writer.WriteField("timestamp_granularities[]", granularity); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ct.cnf.WhisperAsrUri, body)
+	if err != nil {
+		log.Errorf("error in creating request to Whisper ASR. error: %v", err)
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if ct.cnf.WhisperKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ct.cnf.WhisperKey))
+	}
+
+	response, err := ct.client.Do(req)
+	if err != nil {
+		log.Errorf("error in doing request to Whisper ASR. error: %v", err)
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		log.Errorf("error in reading response body from Whisper ASR. error: %v", err)
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		log.Errorf("Whisper ASR returned status %v. body: %s", response.StatusCode, responseBody)
+		return nil, asr.NewStatusError("whisper", response.StatusCode, string(responseBody))
+	}
+
+	if err = json.Unmarshal(responseBody, &result); err != nil {
+		log.Errorf("error in umarshaling Whisper ASR body. error: %v", err)
+		return nil, err
+	}
+
+	return &result, nil
+}
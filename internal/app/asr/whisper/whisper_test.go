@@ -0,0 +1,82 @@
+package whisper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const verboseJSON = `{
+	"task": "transcribe",
+	"language": "russian",
+	"duration": 3.54,
+	"text": " Добрый день. У меня вопрос про мой остаток на счету.",
+	"segments": [
+		{"id": 0, "seek": 0, "start": 0.88, "end": 1.16, "text": " Добрый день.", "avg_logprob": -0.2, "no_speech_prob": 0.01},
+		{"id": 1, "seek": 0, "start": 1.74, "end": 3.54, "text": " У меня вопрос про мой остаток на счету.", "avg_logprob": -0.3, "no_speech_prob": 0.02}
+	],
+	"words": [
+		{"word": "Добрый", "start": 0.88, "end": 1.0, "probability": 0.9},
+		{"word": "день", "start": 1.0, "end": 1.16, "probability": 0.8}
+	]
+}`
+
+func TestServiceASRWhisper_Recognize(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "verbose_json", r.FormValue("response_format"))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		assert.Equal(t, "en", r.FormValue("language"))
+		assert.Equal(t, []string{"segment", "word"}, r.MultipartForm.Value["timestamp_granularities[]"])
+
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, "RIFF", string(data))
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, verboseJSON)
+	}))
+	defer server.Close()
+
+	service := NewWhisperASRStore(config.WhisperAsr{WhisperAsrUri: server.URL, WhisperKey: "secret", Model: "whisper-1", Language: "ru"})
+
+	transcript, err := service.Recognize(context.Background(), asr.Audio{Data: []byte("RIFF"), Format: asr.FormatWAV}, asr.Options{Language: "en"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Добрый день. У меня вопрос про мой остаток на счету.", transcript.Text)
+	require.Len(t, transcript.Segments, 2)
+	assert.Equal(t, float32(0.88), transcript.Segments[0].StartTime)
+	assert.Equal(t, float32(1.16), transcript.Segments[0].EndTime)
+	assert.InDelta(t, 0.8187, transcript.Segments[0].Confidence, 1e-3)
+	assert.Equal(t, []asr.Word{
+		{Text: "Добрый", StartTime: 0.88, EndTime: 1.0, Confidence: 0.9},
+		{Text: "день", StartTime: 1.0, EndTime: 1.16, Confidence: 0.8},
+	}, transcript.Segments[0].Words)
+	assert.Equal(t, asr.Segment{ChannelTag: "1", Text: "У меня вопрос про мой остаток на счету.", StartTime: 1.74, EndTime: 3.54, Confidence: transcript.Segments[1].Confidence}, transcript.Segments[1])
+}
+
+func TestServiceASRWhisper_ErrorStatus(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model is loading", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := NewWhisperASRStore(config.WhisperAsr{WhisperAsrUri: server.URL}).Recognize(context.Background(), asr.Audio{Data: []byte("RIFF")}, asr.Options{})
+
+	var statusErr *asr.StatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	}
+}
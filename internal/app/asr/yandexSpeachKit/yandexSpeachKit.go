@@ -2,10 +2,12 @@ package yandexspeachkit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/RecoBattle/cmd/config"
@@ -13,8 +15,15 @@ import (
 	"github.com/labstack/gommon/log"
 )
 
+const (
+	defaultLanguage        = "ru-RU"
+	defaultSampleRateHertz = 8000
+)
+
 type Response struct {
-	Data string `json:"result"`
+	Data         string `json:"result"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
 }
 
 type ServiceASRYandex struct {
@@ -33,16 +42,42 @@ func NewYandexASRStore(cnf config.YandexAsr) *ServiceASRYandex {
 	}
 }
 
-func (ct ServiceASRYandex) TextFromASRModel(data []byte) (string, error) {
+// Requirements SpeechKit v1 принимает моно LPCM 16 бит с частотой из настроек
+func (ct ServiceASRYandex) Requirements() asr.Requirements {
+
+	sampleRateHertz, err := strconv.Atoi(ct.cnf.SampleRateHertz)
+	if err != nil || sampleRateHertz <= 0 {
+		sampleRateHertz = defaultSampleRateHertz
+	}
+
+	return asr.Requirements{
+		Format:          asr.FormatLPCM,
+		SampleRateHertz: sampleRateHertz,
+		BitsPerSample:   16,
+		Channels:        1,
+	}
+}
+
+func (ct ServiceASRYandex) Recognize(ctx context.Context, audio asr.Audio, opts asr.Options) (*asr.Transcript, error) {
 	var result Response
 
-	uri := fmt.Sprintf("%v?topic=%v&folderId=%v&lang=ru-RU&format=%v&sampleRateHertz=%v", ct.cnf.YandexAsrUri, "general", ct.cnf.YandexFolderId, ct.cnf.Format, ct.cnf.SampleRateHertz)
+	language := opts.Language
+	if language == "" {
+		language = defaultLanguage
+	}
+
+	sampleRateHertz := ct.cnf.SampleRateHertz
+	if audio.SampleRateHertz > 0 {
+		sampleRateHertz = strconv.Itoa(audio.SampleRateHertz)
+	}
+
+	uri := fmt.Sprintf("%v?topic=%v&folderId=%v&lang=%v&format=%v&sampleRateHertz=%v", ct.cnf.YandexAsrUri, "general", ct.cnf.YandexFolderId, language, asr.FormatLPCM, sampleRateHertz)
 	log.Infof("Yandex request uri: %v", uri)
 
-	req, err := http.NewRequest("POST", uri, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewBuffer(audio.Data))
 	if err != nil {
 		log.Errorf("error in creating request to Yandex ASR. error: %v", err)
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Add("Authorization", fmt.Sprintf("Api-Key %s", ct.cnf.YandexKey))
@@ -51,7 +86,7 @@ func (ct ServiceASRYandex) TextFromASRModel(data []byte) (string, error) {
 
 	if err != nil {
 		log.Errorf("error in doing request to Yandex ASR. error: %v", err)
-		return "", err
+		return nil, err
 	}
 
 	defer response.Body.Close()
@@ -59,15 +94,34 @@ func (ct ServiceASRYandex) TextFromASRModel(data []byte) (string, error) {
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
 		log.Errorf("error in reading response body from Yandex ASR. error: %v", err)
-		return "", err
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		message := string(responseBody)
+		if json.Unmarshal(responseBody, &result) == nil && result.ErrorCode != "" {
+			message = fmt.Sprintf("%v %v", result.ErrorCode, result.ErrorMessage)
+		}
+		log.Errorf("Yandex ASR returned status %v. error: %v", response.StatusCode, message)
+		return nil, asr.NewStatusError("yandex", response.StatusCode, message)
 	}
 
 	err = json.Unmarshal(responseBody, &result)
 	if err != nil {
 		log.Errorf("error in umarshaling Yandex ASR body. error: %v", err)
-		return "", err
+		return nil, err
+	}
+
+	transcript := &asr.Transcript{Text: result.Data}
+
+	if result.Data != "" {
+		transcript.Segments = append(transcript.Segments, asr.Segment{
+			ChannelTag: "1",
+			Text:       result.Data,
+			EndTime:    audio.Duration(),
+		})
 	}
 
-	return result.Data, nil
+	return transcript, nil
 
 }
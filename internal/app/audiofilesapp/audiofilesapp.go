@@ -1,16 +1,26 @@
 package audiofilesapp
 
 import (
+	"bytes"
 	"context"
-	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
 	"time"
 
+	"github.com/RecoBattle/cmd/config"
 	"github.com/RecoBattle/internal/app/asr"
+	"github.com/RecoBattle/internal/app/pricing"
+	"github.com/RecoBattle/internal/app/transcode"
+	"github.com/RecoBattle/internal/app/wav"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/storage"
 	"github.com/google/uuid"
-	"github.com/labstack/gommon/log"
 )
 
 const (
@@ -18,6 +28,7 @@ const (
 	StatusPROCESSING = "PROCESSING"
 	StatusINVALID    = "INVALID"
 	StatusPROCESSED  = "PROCESSED"
+	StatusFAILED     = "FAILED"
 
 	//ASRYaSpeachKit = "yandexSpeachKit"
 	//ASRSalut       = "salut"
@@ -26,122 +37,381 @@ const (
 )
 
 type AudioFile struct {
-	UUID       uuid.UUID `json:"uuid"`
-	FileID     string    `json:"id_file"`
-	FileName   string    `json:"file_name"`
-	ASR        string    `json:"asr"`
-	Status     string    `json:"status"`
-	UploadedAt time.Time `json:"uploaded_at"`
-	UserID     string    `json:"-"`
-	Data       []byte    `json:"-"`
+	UUID        uuid.UUID     `json:"uuid"`
+	FileID      string        `json:"id_file"`
+	FileName    string        `json:"file_name"`
+	ASR         string        `json:"asr"`
+	Status      string        `json:"status"`
+	Attempts    int           `json:"attempts"`
+	LastError   string        `json:"last_error,omitempty"`
+	UploadedAt  time.Time     `json:"uploaded_at"`
+	Audio       wav.Info      `json:"audio"`
+	Cost        *pricing.Cost `json:"cost,omitempty"`
+	Timings     *Timings      `json:"timings,omitempty"`
+	UserID      string        `json:"-"`
+	WorkspaceID string        `json:"workspace_id,omitempty"`
+	Hash        string        `json:"-"`
+	StorageKey  string        `json:"-"`
+	ClaimID     string        `json:"-"`
+	Language    string        `json:"-"`
+	Data        []byte        `json:"-"`
 }
 
 type ResultASR struct {
-	UUID       uuid.UUID `json:"-"`
-	ChannelTag string    `json:"channelTag"`
-	Text       string    `json:"text"`
-	StartTime  float32   `json:"startTime"`
-	EndTime    float32   `json:"endTime"`
+	UUID       uuid.UUID  `json:"-"`
+	ChannelTag string     `json:"channelTag"`
+	Text       string     `json:"text"`
+	StartTime  float32    `json:"startTime"`
+	EndTime    float32    `json:"endTime"`
+	Confidence float32    `json:"confidence"`
+	Words      []asr.Word `json:"words,omitempty"`
 }
 
 type AudioFileStore interface {
 	CreateFile(ctx context.Context, audioFile AudioFile) error
 	CreateASR(ctx context.Context, audioFile AudioFile) error
-	UpdateStatusASR(ctx context.Context, audioFileUUID, status string) error
-	CreateResultASR(ctx context.Context, resultASR ResultASR) error
-	GetAudioFiles(ctx context.Context, userID string) (*[]AudioFile, error)
+	CreateASRs(ctx context.Context, jobs []AudioFile) ([]AudioFile, error)
+	GetFile(ctx context.Context, scope workspaceapp.Scope, fileID string) (*AudioFile, error)
+	ClaimASR(ctx context.Context, instance string) (*AudioFile, error)
+	ResumeASR(ctx context.Context, instance string) (int64, error)
+	HeartbeatASR(ctx context.Context, audioFileUUID, claimID string) error
+	ReleaseStaleASR(ctx context.Context, staleBefore time.Time) (int64, error)
+	RetryASR(ctx context.Context, audioFileUUID, claimID, lastError string, upstream time.Duration, nextAttemptAt time.Time) error
+	FailASR(ctx context.Context, audioFileUUID, claimID, status, lastError string, upstream time.Duration) error
+	RequeueASR(ctx context.Context, scope workspaceapp.Scope, audioFileUUID string) error
+	SaveResultASR(ctx context.Context, audioFileUUID, claimID string, resultASR []ResultASR, upstream time.Duration) error
+	GetAudioFiles(ctx context.Context, scope workspaceapp.Scope) (*[]AudioFile, error)
+	GetASR(ctx context.Context, uuid string) (*AudioFile, error)
 	GetResultASR(ctx context.Context, uuid string) (*[]ResultASR, error)
+	GetUsage(ctx context.Context, userID string, since time.Time) ([]Usage, error)
 }
 
 type AudioFiles struct {
 	audioFileStore AudioFileStore
+	asrRegistry    *asr.ASRRegistry
+	fileStorage    storage.Storage
+	cfg            config.Queue
+	instance       string
+	quota          config.Quota
+	prices         *pricing.Prices
+	queued         chan struct{}
 }
 
-func NewAudioFile(audioFileStore AudioFileStore) *AudioFiles {
+func NewAudioFile(audioFileStore AudioFileStore, asrRegistry *asr.ASRRegistry, fileStorage storage.Storage, cfg config.Queue, quota config.Quota,
+	prices *pricing.Prices) *AudioFiles {
 	return &AudioFiles{
 		audioFileStore: audioFileStore,
+		asrRegistry:    asrRegistry,
+		fileStorage:    fileStorage,
+		cfg:            cfg,
+		instance:       instanceName(cfg),
+		quota:          quota,
+		prices:         prices,
+		queued:         make(chan struct{}, 1),
 	}
 }
 
-func (af *AudioFiles) Create(ctx context.Context, audiofile AudioFile) (string, error) {
+// Create проверяет wav-файл, сохраняет его в хранилище и регистрирует в БД вместе с параметрами аудио.
+// Файл определяется по SHA-256 декодированного аудио: повторная загрузка той же записи пользователем, в том числе
+// с другими метаданными wav-файла, возвращает уже существующий id_file.
+// asrNames — ASR, которыми файл будет распознан: квота проверяется до сохранения, и файл сверх квоты не сохраняется
+func (af *AudioFiles) Create(ctx context.Context, audiofile AudioFile, asrNames []string) (string, error) {
 
-	audiofile.FileID = hex.EncodeToString(af.writeHash(audiofile.FileName, audiofile.UserID))
+	info, err := wav.Decode(audiofile.Data)
+	if err != nil {
+		return "", err
+	}
 
-	if err := af.audioFileStore.CreateFile(ctx, audiofile); err != nil {
+	audiofile.Audio = *info
+
+	audiofile.Hash, err = info.ContentHash(bytes.NewReader(audiofile.Data))
+	if err != nil {
+		return "", err
+	}
+
+	raw := sha256.Sum256(audiofile.Data)
+	audiofile.StorageKey = hex.EncodeToString(raw[:]) + ".wav"
+
+	data := audiofile.Data
+	audiofile.Data = nil
+
+	return af.save(ctx, audiofile, asrNames, bytes.NewReader(data), int64(len(data)))
+}
+
+// CreateFromReader делает то же, что Create, но читает файл из потока: он копируется во временный файл
+// с подсчётом хеша файла для ключа хранилища, а хеш аудио считается по блоку данных временного файла
+func (af *AudioFiles) CreateFromReader(ctx context.Context, audiofile AudioFile, asrNames []string, r io.Reader) (string, error) {
+
+	tmp, err := os.CreateTemp("", "recobattle-upload-*.wav")
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := wav.DecodeReader(tmp, size)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	audiofile.Audio = *info
+	audiofile.StorageKey = hex.EncodeToString(hash.Sum(nil)) + ".wav"
+
+	audiofile.Hash, err = info.ContentHash(tmp)
+	if err != nil {
+		return "", err
+	}
+
+	return af.save(ctx, audiofile, asrNames, tmp, size)
+}
+
+// save кладёт проверенный файл в хранилище и регистрирует его в БД, если такой записи в пространстве ещё нет.
+// Ключ хранилища — хеш всего файла, чтобы пространства с одной записью, но разными метаданными не получали чужой файл.
+// Файл рабочего пространства принадлежит пространству, а не загрузившему его участнику
+func (af *AudioFiles) save(ctx context.Context, audiofile AudioFile, asrNames []string, r io.Reader, size int64) (string, error) {
+
+	if err := af.checkQuota(ctx, audiofile.UserID, asrNames, audiofile.Audio.Duration); err != nil {
+		return "", err
+	}
+
+	scope := workspaceapp.Scope{UserID: audiofile.UserID, WorkspaceID: audiofile.WorkspaceID}
+
+	audiofile.FileID = fileID(scope.Owner(), audiofile.Hash)
+
+	existing, err := af.audioFileStore.GetFile(ctx, scope, audiofile.FileID)
+	if err == nil {
+		return existing.FileID, nil
+	}
+
+	var errNotFound *database.NotFoundError
+	if !errors.As(err, &errNotFound) {
+		return "", err
+	}
+
+	if err = af.fileStorage.Put(ctx, audiofile.StorageKey, r, size); err != nil {
+		return "", err
+	}
+
+	if err = af.audioFileStore.CreateFile(ctx, audiofile); err != nil {
+		// тот же файл одновременно загружен параллельным запросом
+		var errConflict *database.ConflictError
+		if errors.As(err, &errConflict) {
+			return audiofile.FileID, nil
+		}
 		return "", err
 	}
 
 	return audiofile.FileID, nil
 }
 
-func (af *AudioFiles) AddASRProcessing(ctx context.Context, asr asr.ASR, inputAudiofile <-chan AudioFile) {
+// Enqueue ставит файл в очередь на распознавание, задание подхватит один из воркеров.
+// Задание записывается на пользователя UserID вместе с длительностью файла для учёта расхода
+func (af *AudioFiles) Enqueue(ctx context.Context, audiofile AudioFile) (string, error) {
 
-	for {
-		select {
-		case audiofile, ok := <-inputAudiofile:
-			if !ok {
-				return
-			}
+	audiofile.UUID = uuid.New()
 
-			audiofile.UUID = uuid.New()
-			if err := af.audioFileStore.CreateASR(ctx, audiofile); err != nil {
-				log.Error(err.Error())
-				//outputCh <- err
-				return
-			}
+	if err := af.audioFileStore.CreateASR(ctx, audiofile); err != nil {
+		return "", err
+	}
 
-			result, err := asr.TextFromASRModel(audiofile.Data)
-			if err != nil {
-				log.Errorf("error in sending request to ASR. error: %#v", result)
-				if err := af.audioFileStore.UpdateStatusASR(ctx, audiofile.UUID.String(), StatusINVALID); err != nil {
-					//outputCh <- err
-					return
-				}
-				//outputCh <- err
-				return
-			}
+	af.notify()
 
-			resASR := ResultASR{
-				UUID:       audiofile.UUID,
-				ChannelTag: "1",
-				Text:       result,
-			}
+	return audiofile.UUID.String(), nil
+}
 
-			if err := af.audioFileStore.CreateResultASR(ctx, resASR); err != nil {
-				log.Errorf("error in writing the ASR result. error: %v", err)
-				if err := af.audioFileStore.UpdateStatusASR(ctx, audiofile.UUID.String(), StatusINVALID); err != nil {
-					//outputCh <- err
-					return
-				}
-				//outputCh <- err
-				return
-			}
+// EnqueueAll ставит загруженный файл в очередь на распознавание каждым из перечисленных ASR. Квоту для этих ASR
+// проверяет Create до сохранения файла. Задания создаются в одной транзакции: при ошибке в очередь не ставится ни одно.
+// ASR, которым файл уже распознаётся, пропускаются
+func (af *AudioFiles) EnqueueAll(ctx context.Context, audiofile AudioFile, asrNames []string) ([]AudioFile, error) {
+
+	jobs := make([]AudioFile, 0, len(asrNames))
+
+	for _, name := range asrNames {
+		jobs = append(jobs, AudioFile{
+			UUID:        uuid.New(),
+			FileID:      audiofile.FileID,
+			FileName:    audiofile.FileName,
+			UserID:      audiofile.UserID,
+			WorkspaceID: audiofile.WorkspaceID,
+			Language:    audiofile.Language,
+			ASR:         name,
+			Status:      StatusNEW,
+		})
+	}
+
+	created, err := af.audioFileStore.CreateASRs(ctx, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(created) > 0 {
+		af.notify()
+	}
+
+	return created, nil
+}
+
+// OpenAudio открывает исходный wav-файл пространства из хранилища. Чужой или отсутствующий файл — NotFoundError
+func (af *AudioFiles) OpenAudio(ctx context.Context, scope workspaceapp.Scope, fileID string) (*AudioFile, io.ReadSeekCloser, error) {
+
+	file, err := af.audioFileStore.GetFile(ctx, scope, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := af.fileStorage.Open(ctx, file.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, database.NewErrorNotFound(err)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, content, nil
+}
+
+// Recognize ставит в очередь распознавание уже загруженного файла пространства выбранным ASR
+func (af *AudioFiles) Recognize(ctx context.Context, scope workspaceapp.Scope, fileID, asrName, language string) (string, error) {
+
+	file, err := af.audioFileStore.GetFile(ctx, scope, fileID)
+	if err != nil {
+		return "", err
+	}
+
+	if err = af.checkQuota(ctx, scope.UserID, []string{asrName}, file.Audio.Duration); err != nil {
+		return "", err
+	}
+
+	// в рабочем пространстве расход записывается на участника, поставившего задание, а не на загрузившего файл
+	file.UserID = scope.UserID
+	file.ASR = asrName
+	file.Language = language
+
+	return af.Enqueue(ctx, *file)
+}
+
+// Requeue возвращает в очередь задание пространства в статусе FAILED или INVALID
+func (af *AudioFiles) Requeue(ctx context.Context, scope workspaceapp.Scope, audioFileUUID string) error {
+
+	if err := af.audioFileStore.RequeueASR(ctx, scope, audioFileUUID); err != nil {
+		return err
+	}
+
+	af.notify()
+
+	return nil
+}
 
-			if err := af.audioFileStore.UpdateStatusASR(ctx, audiofile.UUID.String(), StatusPROCESSED); err != nil {
-				log.Error(err.Error())
-				//outputCh <- err
-				return
+// notify будит воркеров, не дожидаясь следующего опроса очереди
+func (af *AudioFiles) notify() {
+
+	select {
+	case af.queued <- struct{}{}:
+	default:
+	}
+}
+
+// recognize распознаёт файл и возвращает вместе с результатом суммарное время ожидания ответов ASR.
+// При ошибке время, уже потраченное ASR, тоже возвращается: медленные неудачные попытки попадают в замеры
+func (af *AudioFiles) recognize(ctx context.Context, service asr.ASR, audiofile AudioFile) ([]ResultASR, time.Duration, error) {
+
+	var (
+		results  []ResultASR
+		upstream time.Duration
+	)
+
+	// аудио приводится к формату, который принимает конкретный ASR; каналы стерео-записи распознаются по отдельности
+	audios, err := transcode.Split(audiofile.Data, service.Requirements())
+	if err != nil {
+		return nil, upstream, err
+	}
+
+	for i, audio := range audios {
+
+		start := time.Now()
+
+		transcript, err := service.Recognize(ctx, audio, asr.Options{Language: audiofile.Language})
+
+		upstream += time.Since(start)
+
+		if err != nil {
+			return nil, upstream, err
+		}
+
+		for _, segment := range transcript.Segments {
+
+			channelTag := segment.ChannelTag
+			if len(audios) > 1 {
+				channelTag = strconv.Itoa(i + 1)
 			}
 
-		case <-ctx.Done():
-			log.Error(ctx.Err())
-			return
+			results = append(results, ResultASR{
+				UUID:       audiofile.UUID,
+				ChannelTag: channelTag,
+				Text:       segment.Text,
+				StartTime:  segment.StartTime,
+				EndTime:    segment.EndTime,
+				Confidence: segment.Confidence,
+				Words:      segment.Words,
+			})
 		}
 	}
+
+	return results, upstream, nil
 }
 
-func (af *AudioFiles) GetAudioFiles(ctx context.Context, userID string) (*[]AudioFile, error) {
+func (af *AudioFiles) GetAudioFiles(ctx context.Context, scope workspaceapp.Scope) (*[]AudioFile, error) {
 
-	files, err := af.audioFileStore.GetAudioFiles(ctx, userID)
+	files, err := af.audioFileStore.GetAudioFiles(ctx, scope)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// стоимость задания по тарифу ASR и длительности файла, длительности этапов обработки и RTF
+	for i := range *files {
+
+		file := &(*files)[i]
+
+		if file.ASR != "" {
+			file.Cost, _ = af.prices.Cost(file.ASR, file.Audio.Duration)
+		}
+
+		if file.Timings != nil {
+			file.Timings.complete(file.Status, file.Audio.Duration)
+		}
+	}
+
 	return files, nil
 }
 
-func (af *AudioFiles) GetResultASR(ctx context.Context, uuid string) (*[]ResultASR, error) {
+// GetResultASR возвращает результат распознавания, только если задание относится к файлу пространства.
+// Задание чужого файла не отличается от отсутствующего — NotFoundError
+func (af *AudioFiles) GetResultASR(ctx context.Context, scope workspaceapp.Scope, uuid string) (*[]ResultASR, error) {
+
+	job, err := af.audioFileStore.GetASR(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !scope.Contains(job.UserID, job.WorkspaceID) {
+		return nil, database.NewErrorNotFound(errors.New(uuid))
+	}
 
 	resultASR, err := af.audioFileStore.GetResultASR(ctx, uuid)
 
@@ -152,12 +422,10 @@ func (af *AudioFiles) GetResultASR(ctx context.Context, uuid string) (*[]ResultA
 	return resultASR, nil
 }
 
-func (af *AudioFiles) writeHash(filename, userID string) []byte {
-
-	secretKey := "file2468"
+// fileID идентификатор файла пространства по хэшу содержимого; для личного пространства owner — id пользователя
+func fileID(owner, hash string) string {
 
-	hash := hmac.New(sha256.New, []byte(secretKey))
-	hash.Write([]byte(fmt.Sprintf("%s:%s:%s", filename, userID, secretKey)))
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", owner, hash)))
 
-	return hash.Sum(nil)
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,96 @@
+package audiofilesapp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAudioFiles_EnqueueAll(t *testing.T) {
+
+	file := audiofilesapp.AudioFile{FileID: "file", FileName: "rec.wav", UserID: userID, Language: "ru-RU"}
+
+	jobs := func(asrNames ...string) []audiofilesapp.AudioFile {
+		var result []audiofilesapp.AudioFile
+		for _, name := range asrNames {
+			job := file
+			job.UUID = mocks.JobUUID
+			job.ASR = name
+			job.Status = audiofilesapp.StatusNEW
+			result = append(result, job)
+		}
+		return result
+	}
+
+	t.Run("Single transaction", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		// cloud уже распознаёт этот файл
+		store.On("CreateASRs", mock.Anything, jobs("fake", "cloud")).Return(jobs("fake"), nil).Once()
+
+		created, err := newQuotaApp(t, store, noQuota).EnqueueAll(context.Background(), file, []string{"fake", "cloud"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, jobs("fake"), created)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("CreateASRs", mock.Anything, jobs("fake", "cloud")).Return([]audiofilesapp.AudioFile(nil), errors.New("connection reset"))
+
+		created, err := newQuotaApp(t, store, noQuota).EnqueueAll(context.Background(), file, []string{"fake", "cloud"})
+
+		assert.Error(t, err)
+		assert.Empty(t, created)
+		store.AssertNotCalled(t, "CreateASR", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAudioFiles_GetResultASR(t *testing.T) {
+
+	const jobUUID = "e1d9a4f2-3c7b-4a8e-9f60-2b5d8c1a7e34"
+
+	result := &[]audiofilesapp.ResultASR{{ChannelTag: "1", Text: "добрый день"}}
+
+	tests := []struct {
+		name    string
+		job     audiofilesapp.AudioFile
+		scope   workspaceapp.Scope
+		wantErr bool
+	}{
+		{"Own job", audiofilesapp.AudioFile{UserID: userID}, workspaceapp.Personal(userID), false},
+		{"Another user's job", audiofilesapp.AudioFile{UserID: "other"}, workspaceapp.Personal(userID), true},
+		{"Job of the workspace", audiofilesapp.AudioFile{UserID: "other", WorkspaceID: "ws"}, workspaceapp.Scope{UserID: userID, WorkspaceID: "ws"}, false},
+		{"Job of another workspace", audiofilesapp.AudioFile{UserID: userID, WorkspaceID: "ws-2"}, workspaceapp.Scope{UserID: userID, WorkspaceID: "ws"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			store := new(mocks.MockAudioFileStore)
+			store.On("GetASR", mock.Anything, jobUUID).Return(&tt.job, nil)
+			store.On("GetResultASR", mock.Anything, jobUUID).Return(result, nil)
+
+			got, err := newQuotaApp(t, store, noQuota).GetResultASR(context.Background(), tt.scope, jobUUID)
+
+			if tt.wantErr {
+				var notFound *database.NotFoundError
+				assert.ErrorAs(t, err, &notFound)
+				store.AssertNotCalled(t, "GetResultASR", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, result, got)
+		})
+	}
+}
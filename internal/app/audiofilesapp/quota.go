@@ -0,0 +1,180 @@
+package audiofilesapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// ErrQuotaExceeded задание превысило бы квоту аудио-минут пользователя или ASR
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Usage расход аудио по одному ASR или по всем сразу. Задание учитывается при постановке в очередь
+// с длительностью файла, повторные попытки распознавания не учитываются
+type Usage struct {
+	ASR          string  `json:"asr,omitempty"`
+	Jobs         int     `json:"jobs"`
+	Seconds      float64 `json:"-"`
+	Minutes      float64 `json:"minutes"`
+	LimitMinutes float64 `json:"limit_minutes,omitempty"`
+}
+
+type UsageReport struct {
+	Period string    `json:"period"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Total  Usage     `json:"total"`
+	ASR    []Usage   `json:"asr"`
+}
+
+// GetUsage расход аудио-минут пользователя за текущий период по каждому ASR вместе с лимитами
+func (af *AudioFiles) GetUsage(ctx context.Context, userID string) (*UsageReport, error) {
+
+	from, to := af.period(time.Now())
+
+	usage, err := af.audioFileStore.GetUsage(ctx, userID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	report := UsageReport{
+		Period: af.quotaPeriod(),
+		From:   from,
+		To:     to,
+		Total:  Usage{LimitMinutes: af.quota.UserMinutes},
+		ASR:    make([]Usage, 0, len(usage)),
+	}
+
+	for _, u := range usage {
+		u.Minutes = minutes(u.Seconds)
+		u.LimitMinutes = af.quota.ASR[u.ASR].UserMinutes
+		report.ASR = append(report.ASR, u)
+
+		report.Total.Jobs += u.Jobs
+		report.Total.Seconds += u.Seconds
+	}
+
+	report.Total.Minutes = minutes(report.Total.Seconds)
+
+	return &report, nil
+}
+
+// checkQuota проверяет, что задания на seconds секунд аудио для каждого из asrNames уложатся в квоты пользователя и ASR.
+// Проверка и постановка в очередь не атомарны, поэтому параллельные загрузки могут немного превысить квоту
+func (af *AudioFiles) checkQuota(ctx context.Context, userID string, asrNames []string, seconds float64) error {
+
+	if !af.quotaEnabled() {
+		return nil
+	}
+
+	from, _ := af.period(time.Now())
+
+	usage, err := af.audioFileStore.GetUsage(ctx, userID, from)
+	if err != nil {
+		return err
+	}
+
+	byASR := make(map[string]float64, len(usage))
+	total := 0.0
+
+	for _, u := range usage {
+		byASR[u.ASR] = u.Seconds
+		total += u.Seconds
+	}
+
+	if limit := af.quota.UserMinutes; limit > 0 && total+seconds*float64(len(asrNames)) > limit*60 {
+		return fmt.Errorf("%w: the %s limit of %g minutes", ErrQuotaExceeded, af.periodName(), limit)
+	}
+
+	// расход всех пользователей запрашивается, только если для какого-то ASR задан общий лимит
+	var allByASR map[string]float64
+
+	for _, name := range asrNames {
+
+		limits := af.quota.ASR[name]
+
+		if limits.UserMinutes > 0 && byASR[name]+seconds > limits.UserMinutes*60 {
+			return fmt.Errorf("%w: the %s limit of %g minutes on %s", ErrQuotaExceeded, af.periodName(), limits.UserMinutes, name)
+		}
+
+		if limits.TotalMinutes <= 0 {
+			continue
+		}
+
+		if allByASR == nil {
+			all, err := af.audioFileStore.GetUsage(ctx, "", from)
+			if err != nil {
+				return err
+			}
+
+			allByASR = make(map[string]float64, len(all))
+			for _, u := range all {
+				allByASR[u.ASR] = u.Seconds
+			}
+		}
+
+		if allByASR[name]+seconds > limits.TotalMinutes*60 {
+			return fmt.Errorf("%w: the %s limit of %g minutes of all users on %s", ErrQuotaExceeded, af.periodName(), limits.TotalMinutes, name)
+		}
+	}
+
+	return nil
+}
+
+func (af *AudioFiles) quotaEnabled() bool {
+
+	if af.quota.UserMinutes > 0 {
+		return true
+	}
+
+	for _, limits := range af.quota.ASR {
+		if limits.UserMinutes > 0 || limits.TotalMinutes > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// period начало и конец текущего периода квоты по времени сервера, в том же часовом поясе пишется created_at заданий
+func (af *AudioFiles) period(now time.Time) (time.Time, time.Time) {
+
+	if af.quotaPeriod() == PeriodDay {
+		from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		return from, from.AddDate(0, 0, 1)
+	}
+
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	return from, from.AddDate(0, 1, 0)
+}
+
+// quotaPeriod период квоты из конфига, по умолчанию месяц
+func (af *AudioFiles) quotaPeriod() string {
+
+	if af.quota.Period == PeriodDay {
+		return PeriodDay
+	}
+
+	return PeriodMonth
+}
+
+func (af *AudioFiles) periodName() string {
+
+	if af.quotaPeriod() == PeriodDay {
+		return "daily"
+	}
+
+	return "monthly"
+}
+
+func minutes(seconds float64) float64 {
+	return math.Round(seconds/60*100) / 100
+}
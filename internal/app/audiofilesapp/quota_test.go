@@ -0,0 +1,176 @@
+package audiofilesapp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/wav"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/RecoBattle/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const userID = "5f0c7e3a-1b2d-4c5e-8f9a-0b1c2d3e4f5a"
+
+var noQuota = config.Quota{}
+
+func newQuotaApp(t *testing.T, store *mocks.MockAudioFileStore, quota config.Quota) *audiofilesapp.AudioFiles {
+
+	fileStorage, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := asr.ASRRegistry{Services: make(map[string]asr.ASR)}
+	registry.AddService("fake", &fakeASR{})
+	registry.AddService("cloud", &fakeASR{})
+
+	return audiofilesapp.NewAudioFile(store, &registry, fileStorage, queueConfig, quota, nil)
+}
+
+// storedFile десятиминутная запись пользователя
+func storedFile(store *mocks.MockAudioFileStore) {
+	store.On("GetFile", mock.Anything, workspaceapp.Personal(userID), "file").
+		Return(&audiofilesapp.AudioFile{FileID: "file", UserID: userID, Audio: wav.Info{Duration: 600}}, nil)
+}
+
+func TestAudioFiles_RecognizeQuota(t *testing.T) {
+
+	quota := config.Quota{ASR: map[string]config.ASRQuota{"cloud": {UserMinutes: 30, TotalMinutes: 100}}}
+
+	t.Run("Within quota", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		storedFile(store)
+		store.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage{{ASR: "cloud", Jobs: 1, Seconds: 1200}}, nil)
+		store.On("GetUsage", mock.Anything, "", mock.Anything).Return([]audiofilesapp.Usage{{ASR: "cloud", Jobs: 8, Seconds: 5400}}, nil)
+		store.On("CreateASR", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := newQuotaApp(t, store, quota).Recognize(context.Background(), workspaceapp.Personal(userID), "file", "cloud", "")
+
+		assert.NoError(t, err)
+		store.AssertNumberOfCalls(t, "CreateASR", 1)
+	})
+
+	t.Run("User limit on ASR", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		storedFile(store)
+		store.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage{{ASR: "cloud", Jobs: 2, Seconds: 1500}}, nil)
+
+		_, err := newQuotaApp(t, store, quota).Recognize(context.Background(), workspaceapp.Personal(userID), "file", "cloud", "")
+
+		assert.ErrorIs(t, err, audiofilesapp.ErrQuotaExceeded)
+		store.AssertNotCalled(t, "CreateASR", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Total limit on ASR", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		storedFile(store)
+		store.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage(nil), nil)
+		store.On("GetUsage", mock.Anything, "", mock.Anything).Return([]audiofilesapp.Usage{{ASR: "cloud", Jobs: 9, Seconds: 5700}}, nil)
+
+		_, err := newQuotaApp(t, store, quota).Recognize(context.Background(), workspaceapp.Personal(userID), "file", "cloud", "")
+
+		assert.ErrorIs(t, err, audiofilesapp.ErrQuotaExceeded)
+		store.AssertNotCalled(t, "CreateASR", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ASR without limits", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		storedFile(store)
+		store.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage{{ASR: "cloud", Jobs: 3, Seconds: 1800}}, nil)
+		store.On("CreateASR", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := newQuotaApp(t, store, quota).Recognize(context.Background(), workspaceapp.Personal(userID), "file", "fake", "")
+
+		assert.NoError(t, err)
+		store.AssertNotCalled(t, "GetUsage", mock.Anything, "", mock.Anything)
+	})
+}
+
+func TestAudioFiles_CreateQuota(t *testing.T) {
+
+	quota := config.Quota{Period: audiofilesapp.PeriodDay, UserMinutes: 40}
+
+	// минутная запись, два задания по минуте
+	minute := wav.Encode(wav.Info{SampleRateHertz: 8000, BitsPerSample: 16, Channels: 1}, make([]byte, 8000*2*60))
+	file := audiofilesapp.AudioFile{FileName: "rec.wav", UserID: userID}
+
+	create := map[string]func(app *audiofilesapp.AudioFiles) (string, error){
+		"Create": func(app *audiofilesapp.AudioFiles) (string, error) {
+			withData := file
+			withData.Data = minute
+			return app.Create(context.Background(), withData, []string{"fake", "cloud"})
+		},
+		"CreateFromReader": func(app *audiofilesapp.AudioFiles) (string, error) {
+			return app.CreateFromReader(context.Background(), file, []string{"fake", "cloud"}, bytes.NewReader(minute))
+		},
+	}
+
+	for name, create := range create {
+		t.Run(name, func(t *testing.T) {
+
+			t.Run("Within quota", func(t *testing.T) {
+
+				store := new(mocks.MockAudioFileStore)
+				store.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage{{ASR: "fake", Jobs: 3, Seconds: 1500}}, nil)
+				store.On("GetFile", mock.Anything, workspaceapp.Personal(userID), mock.Anything).Return((*audiofilesapp.AudioFile)(nil), database.NewErrorNotFound(errors.New("file")))
+				store.On("CreateFile", mock.Anything, mock.Anything).Return(nil)
+
+				fileID, err := create(newQuotaApp(t, store, quota))
+
+				assert.NoError(t, err)
+				assert.NotEmpty(t, fileID)
+				store.AssertNumberOfCalls(t, "CreateFile", 1)
+			})
+
+			t.Run("Quota exceeded", func(t *testing.T) {
+
+				// 2300 секунд уже израсходовано, два задания по минуте не укладываются в 40 минут: файл не сохраняется
+				store := new(mocks.MockAudioFileStore)
+				store.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage{{ASR: "fake", Jobs: 3, Seconds: 2300}}, nil)
+
+				_, err := create(newQuotaApp(t, store, quota))
+
+				assert.ErrorIs(t, err, audiofilesapp.ErrQuotaExceeded)
+				store.AssertNotCalled(t, "GetFile", mock.Anything, mock.Anything, mock.Anything)
+				store.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+			})
+		})
+	}
+}
+
+func TestAudioFiles_GetUsage(t *testing.T) {
+
+	store := new(mocks.MockAudioFileStore)
+	store.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage{
+		{ASR: "cloud", Jobs: 2, Seconds: 90},
+		{ASR: "fake", Jobs: 1, Seconds: 30},
+	}, nil)
+
+	app := newQuotaApp(t, store, config.Quota{UserMinutes: 600, ASR: map[string]config.ASRQuota{"cloud": {UserMinutes: 120}}})
+
+	report, err := app.GetUsage(context.Background(), userID)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, audiofilesapp.PeriodMonth, report.Period)
+		assert.Equal(t, 1, report.From.Day())
+		assert.Equal(t, report.From.AddDate(0, 1, 0), report.To)
+		assert.Equal(t, audiofilesapp.Usage{Jobs: 3, Seconds: 120, Minutes: 2, LimitMinutes: 600}, report.Total)
+		assert.Equal(t, []audiofilesapp.Usage{
+			{ASR: "cloud", Jobs: 2, Seconds: 90, Minutes: 1.5, LimitMinutes: 120},
+			{ASR: "fake", Jobs: 1, Seconds: 30, Minutes: 0.5},
+		}, report.ASR)
+	}
+}
@@ -0,0 +1,53 @@
+package audiofilesapp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+)
+
+// retryable проверяет, можно ли повторить запрос к ASR после такой ошибки
+func retryable(err error, policy config.RetryPolicy) bool {
+
+	var statusErr *asr.StatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(policy.RetryableStatusCodes, statusErr.StatusCode)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return policy.RetryOnTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return policy.RetryOnTimeout
+		}
+		return policy.RetryOnNetworkError
+	}
+
+	return false
+}
+
+// backoff возвращает задержку перед попыткой attempt+1: InitialBackoff * Multiplier^(attempt-1), но не больше MaxBackoff
+func backoff(policy config.RetryPolicy, attempt int) time.Duration {
+
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(max(attempt-1, 0)))
+
+	if policy.MaxBackoff > 0 {
+		delay = min(delay, float64(policy.MaxBackoff))
+	}
+
+	return time.Duration(delay * float64(time.Second))
+}
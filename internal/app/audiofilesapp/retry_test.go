@@ -0,0 +1,38 @@
+package audiofilesapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryable(t *testing.T) {
+
+	policy := config.RetryPolicy{RetryableStatusCodes: []int{429, 503}, RetryOnTimeout: true}
+
+	assert.True(t, retryable(asr.NewStatusError("yandex", 503, ""), policy))
+	assert.True(t, retryable(fmt.Errorf("wrapped: %w", asr.NewStatusError("yandex", 429, "")), policy))
+	assert.False(t, retryable(asr.NewStatusError("yandex", 400, ""), policy))
+	assert.True(t, retryable(context.DeadlineExceeded, policy))
+	assert.False(t, retryable(&net.OpError{Op: "dial", Err: errors.New("connection refused")}, policy))
+	assert.True(t, retryable(&net.OpError{Op: "dial", Err: errors.New("connection refused")}, config.RetryPolicy{RetryOnNetworkError: true}))
+	assert.False(t, retryable(errors.New("invalid audio"), policy))
+}
+
+func TestBackoff(t *testing.T) {
+
+	policy := config.RetryPolicy{InitialBackoff: 2, MaxBackoff: 10, Multiplier: 2}
+
+	assert.Equal(t, 2*time.Second, backoff(policy, 1))
+	assert.Equal(t, 4*time.Second, backoff(policy, 2))
+	assert.Equal(t, 8*time.Second, backoff(policy, 3))
+	assert.Equal(t, 10*time.Second, backoff(policy, 4))
+	assert.Equal(t, 2*time.Second, backoff(config.RetryPolicy{InitialBackoff: 2}, 5))
+}
@@ -0,0 +1,160 @@
+package audiofilesapp
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/RecoBattle/internal/app/workspaceapp"
+)
+
+// Timings время прохождения задания через очередь. StartedAt и FinishedAt относятся к последней попытке распознавания,
+// QueueSeconds — ожидание от постановки в очередь до её начала вместе с предыдущими попытками
+type Timings struct {
+	QueuedAt          time.Time  `json:"queued_at"`
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	QueueSeconds      *float64   `json:"queue_seconds,omitempty"`
+	ProcessingSeconds *float64   `json:"processing_seconds,omitempty"`
+	UpstreamSeconds   *float64   `json:"upstream_seconds,omitempty"`
+	RTF               *float64   `json:"rtf,omitempty"`
+}
+
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// ASRStats распределение скорости распознавания одного ASR. RTF считается по успешно распознанным заданиям,
+// остальные замеры — также по последней попытке заданий, завершившихся ошибкой
+type ASRStats struct {
+	ASR               string      `json:"asr"`
+	Jobs              int         `json:"jobs"`
+	FailedJobs        int         `json:"failed_jobs"`
+	RTF               Percentiles `json:"rtf"`
+	ProcessingSeconds Percentiles `json:"processing_seconds"`
+	UpstreamSeconds   Percentiles `json:"upstream_seconds"`
+	QueueSeconds      Percentiles `json:"queue_seconds"`
+}
+
+// complete досчитывает длительности этапов и real-time factor: время обработки последней попытки, делённое на длительность аудио
+func (t *Timings) complete(status string, duration float64) {
+
+	if t.StartedAt == nil {
+		return
+	}
+
+	queue := t.StartedAt.Sub(t.QueuedAt).Seconds()
+	t.QueueSeconds = &queue
+
+	if t.FinishedAt == nil {
+		return
+	}
+
+	processing := t.FinishedAt.Sub(*t.StartedAt).Seconds()
+	t.ProcessingSeconds = &processing
+
+	if status == StatusPROCESSED && duration > 0 {
+		rtf := processing / duration
+		t.RTF = &rtf
+	}
+}
+
+// GetStats перцентили RTF, времени обработки, задержки ответа ASR и ожидания в очереди по каждому ASR для заданий пространства
+func (af *AudioFiles) GetStats(ctx context.Context, scope workspaceapp.Scope) ([]ASRStats, error) {
+
+	files, err := af.GetAudioFiles(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	type samples struct {
+		rtf, processing, upstream, queue []float64
+		failed                           int
+	}
+
+	byASR := make(map[string]*samples)
+
+	for _, file := range *files {
+
+		// задания, завершённые до появления замеров, и незавершённые задания не учитываются
+		if file.Timings == nil || file.Timings.ProcessingSeconds == nil {
+			continue
+		}
+
+		failed := file.Status == StatusFAILED || file.Status == StatusINVALID
+
+		if !failed && (file.Status != StatusPROCESSED || file.Timings.RTF == nil) {
+			continue
+		}
+
+		s, ok := byASR[file.ASR]
+		if !ok {
+			s = &samples{}
+			byASR[file.ASR] = s
+		}
+
+		if failed {
+			s.failed++
+		} else {
+			s.rtf = append(s.rtf, *file.Timings.RTF)
+		}
+
+		s.processing = append(s.processing, *file.Timings.ProcessingSeconds)
+		s.queue = append(s.queue, *file.Timings.QueueSeconds)
+
+		if file.Timings.UpstreamSeconds != nil {
+			s.upstream = append(s.upstream, *file.Timings.UpstreamSeconds)
+		}
+	}
+
+	stats := make([]ASRStats, 0, len(byASR))
+
+	for name, s := range byASR {
+		stats = append(stats, ASRStats{
+			ASR:               name,
+			Jobs:              len(s.rtf),
+			FailedJobs:        s.failed,
+			RTF:               percentiles(s.rtf),
+			ProcessingSeconds: percentiles(s.processing),
+			UpstreamSeconds:   percentiles(s.upstream),
+			QueueSeconds:      percentiles(s.queue),
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ASR < stats[j].ASR })
+
+	return stats, nil
+}
+
+func percentiles(values []float64) Percentiles {
+
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	return Percentiles{
+		P50: percentile(sorted, 0.5),
+		P90: percentile(sorted, 0.9),
+		P95: percentile(sorted, 0.95),
+		P99: percentile(sorted, 0.99),
+	}
+}
+
+// percentile перцентиль отсортированной выборки с линейной интерполяцией между соседними значениями, как percentile_cont в PostgreSQL
+func percentile(sorted []float64, p float64) float64 {
+
+	rank := p * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+
+	value := sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+
+	return math.Round(value*1000) / 1000
+}
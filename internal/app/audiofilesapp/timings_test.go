@@ -0,0 +1,124 @@
+package audiofilesapp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/wav"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// processed задание минутной записи: ждало в очереди queue секунд, обрабатывалось processing секунд
+func processed(asrName string, queue, processing, upstream float64) audiofilesapp.AudioFile {
+
+	queuedAt := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	startedAt := queuedAt.Add(time.Duration(queue * float64(time.Second)))
+	finishedAt := startedAt.Add(time.Duration(processing * float64(time.Second)))
+
+	return audiofilesapp.AudioFile{
+		ASR:     asrName,
+		Status:  audiofilesapp.StatusPROCESSED,
+		Audio:   wav.Info{Duration: 60},
+		Timings: &audiofilesapp.Timings{QueuedAt: queuedAt, StartedAt: &startedAt, FinishedAt: &finishedAt, UpstreamSeconds: &upstream},
+	}
+}
+
+// failed задание, последняя попытка которого завершилась ошибкой
+func failed(asrName string, queue, processing, upstream float64) audiofilesapp.AudioFile {
+
+	file := processed(asrName, queue, processing, upstream)
+	file.Status = audiofilesapp.StatusFAILED
+
+	return file
+}
+
+// retrying задание, вернувшееся в очередь после неудачной попытки
+func retrying(asrName string, queue, processing, upstream float64) audiofilesapp.AudioFile {
+
+	file := processed(asrName, queue, processing, upstream)
+	file.Status = audiofilesapp.StatusNEW
+
+	return file
+}
+
+func TestAudioFiles_GetAudioFilesTimings(t *testing.T) {
+
+	startedAt := time.Date(2026, 10, 17, 9, 0, 2, 0, time.UTC)
+
+	files := []audiofilesapp.AudioFile{
+		processed("vosk", 1, 6, 5),
+		// задание ещё распознаётся
+		{ASR: "vosk", Status: audiofilesapp.StatusPROCESSING, Audio: wav.Info{Duration: 60},
+			Timings: &audiofilesapp.Timings{QueuedAt: startedAt.Add(-2 * time.Second), StartedAt: &startedAt}},
+		// распознано до появления замеров
+		{ASR: "vosk", Status: audiofilesapp.StatusPROCESSED, Audio: wav.Info{Duration: 60}},
+	}
+
+	store := new(mocks.MockAudioFileStore)
+	store.On("GetAudioFiles", mock.Anything, workspaceapp.Personal(userID)).Return(&files, nil)
+
+	result, err := newQuotaApp(t, store, noQuota).GetAudioFiles(context.Background(), workspaceapp.Personal(userID))
+	require.NoError(t, err)
+
+	timings := (*result)[0].Timings
+	if assert.NotNil(t, timings.RTF) {
+		assert.InDelta(t, 0.1, *timings.RTF, 1e-9)
+		assert.InDelta(t, 6, *timings.ProcessingSeconds, 1e-9)
+		assert.InDelta(t, 1, *timings.QueueSeconds, 1e-9)
+	}
+
+	timings = (*result)[1].Timings
+	assert.InDelta(t, 2, *timings.QueueSeconds, 1e-9)
+	assert.Nil(t, timings.ProcessingSeconds)
+	assert.Nil(t, timings.RTF)
+
+	assert.Nil(t, (*result)[2].Timings)
+}
+
+func TestAudioFiles_GetStats(t *testing.T) {
+
+	files := []audiofilesapp.AudioFile{
+		processed("vosk", 1, 6, 5),
+		processed("vosk", 3, 12, 10),
+		processed("vosk", 2, 30, 28),
+		processed("cloud", 0, 3, 2.5),
+		// последняя попытка упала по таймауту ASR: в RTF не входит, но остальные замеры учитываются
+		failed("cloud", 1, 30, 29),
+		// ждёт повтора после неудачной попытки
+		retrying("cloud", 0, 10, 9),
+		// упало до появления замеров
+		{ASR: "cloud", Status: audiofilesapp.StatusFAILED},
+	}
+
+	store := new(mocks.MockAudioFileStore)
+	store.On("GetAudioFiles", mock.Anything, workspaceapp.Personal(userID)).Return(&files, nil)
+
+	stats, err := newQuotaApp(t, store, noQuota).GetStats(context.Background(), workspaceapp.Personal(userID))
+	require.NoError(t, err)
+
+	assert.Equal(t, []audiofilesapp.ASRStats{
+		{
+			ASR:               "cloud",
+			Jobs:              1,
+			FailedJobs:        1,
+			RTF:               audiofilesapp.Percentiles{P50: 0.05, P90: 0.05, P95: 0.05, P99: 0.05},
+			ProcessingSeconds: audiofilesapp.Percentiles{P50: 16.5, P90: 27.3, P95: 28.65, P99: 29.73},
+			UpstreamSeconds:   audiofilesapp.Percentiles{P50: 15.75, P90: 26.35, P95: 27.675, P99: 28.735},
+			QueueSeconds:      audiofilesapp.Percentiles{P50: 0.5, P90: 0.9, P95: 0.95, P99: 0.99},
+		},
+		{
+			ASR:               "vosk",
+			Jobs:              3,
+			RTF:               audiofilesapp.Percentiles{P50: 0.2, P90: 0.44, P95: 0.47, P99: 0.494},
+			ProcessingSeconds: audiofilesapp.Percentiles{P50: 12, P90: 26.4, P95: 28.2, P99: 29.64},
+			UpstreamSeconds:   audiofilesapp.Percentiles{P50: 10, P90: 24.4, P95: 26.2, P99: 27.64},
+			QueueSeconds:      audiofilesapp.Percentiles{P50: 2, P90: 2.8, P95: 2.9, P99: 2.98},
+		},
+	}, stats)
+}
@@ -0,0 +1,291 @@
+package audiofilesapp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/database"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultLease        = 5 * time.Minute
+)
+
+var errUnknownASR = errors.New("asr service is not registered")
+
+// RunWorkers возвращает в очередь задания, оставшиеся в PROCESSING после остановки этого экземпляра сервиса,
+// и запускает пул воркеров вместе с возвратом в очередь заданий с истёкшей арендой.
+// Блокируется до отмены ctx и завершения всех воркеров.
+func (af *AudioFiles) RunWorkers(ctx context.Context) {
+
+	workers := af.cfg.Workers
+	pollInterval := time.Duration(af.cfg.PollInterval) * time.Second
+
+	resumed, err := af.audioFileStore.ResumeASR(ctx, af.instance)
+	if err != nil {
+		log.Errorf("error in resuming ASR jobs. error: %v", err)
+	} else if resumed > 0 {
+		log.Infof("resumed %d ASR jobs", resumed)
+	}
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		af.releaseStale(ctx)
+	}()
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			af.worker(ctx, pollInterval)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// instanceName имя экземпляра сервиса, которым помечаются забранные задания. Задания других экземпляров,
+// работающих с той же БД, при старте не трогаются, поэтому имя должно быть уникальным и постоянным между перезапусками
+func instanceName(cfg config.Queue) string {
+
+	if cfg.Instance != "" {
+		return cfg.Instance
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warnf("error in getting the host name for the queue instance. error: %v", err)
+	}
+
+	return hostname
+}
+
+// lease срок аренды задания: попытка продлевает её, пока распознаёт файл
+func (af *AudioFiles) lease() time.Duration {
+
+	if af.cfg.Lease == 0 {
+		return defaultLease
+	}
+
+	return time.Duration(af.cfg.Lease) * time.Second
+}
+
+// releaseStale возвращает в очередь задания всех экземпляров, аренда которых истекла: экземпляр, забравший их,
+// упал и под тем же именем не вернулся
+func (af *AudioFiles) releaseStale(ctx context.Context) {
+
+	ticker := time.NewTicker(af.lease() / 2)
+	defer ticker.Stop()
+
+	for {
+		released, err := af.audioFileStore.ReleaseStaleASR(ctx, time.Now().Add(-af.lease()))
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("error in releasing stale ASR jobs. error: %v", err)
+		} else if released > 0 {
+			log.Warnf("returned %d ASR jobs with an expired lease to the queue", released)
+			af.notify()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// keepAlive продлевает аренду задания, пока не отменён ctx. Если задание больше не принадлежит попытке, попытка
+// прерывается через cancel. Возвращённый канал закрывается после остановки
+func (af *AudioFiles) keepAlive(ctx context.Context, cancel context.CancelFunc, job AudioFile) <-chan struct{} {
+
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(af.lease() / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := af.audioFileStore.HeartbeatASR(ctx, job.UUID.String(), job.ClaimID)
+			if released(job, err) {
+				cancel()
+				return
+			}
+
+			if err != nil && ctx.Err() == nil {
+				log.Errorf("error in extending the lease of ASR job %v. error: %v", job.UUID, err)
+			}
+		}
+	}()
+
+	return stopped
+}
+
+func (af *AudioFiles) worker(ctx context.Context, pollInterval time.Duration) {
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for af.ProcessNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-af.queued:
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessNext забирает одно задание из очереди и распознаёт его. Возвращает false, если очередь пуста.
+func (af *AudioFiles) ProcessNext(ctx context.Context) bool {
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := af.audioFileStore.ClaimASR(ctx, af.instance)
+	if err != nil {
+		log.Errorf("error in claiming ASR job. error: %v", err)
+		return false
+	}
+
+	if job == nil {
+		return false
+	}
+
+	af.process(ctx, *job)
+
+	return true
+}
+
+func (af *AudioFiles) process(ctx context.Context, job AudioFile) {
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	stopped := af.keepAlive(jobCtx, cancel, job)
+
+	results, upstream, err := af.runJob(jobCtx, job)
+
+	// аренду отменил keepAlive: задание больше не принадлежит попытке
+	lost := jobCtx.Err() != nil
+
+	cancel()
+	<-stopped
+
+	if ctx.Err() != nil {
+		log.Infof("ASR job %v interrupted, it will be resumed on restart", job.UUID)
+		return
+	}
+
+	if lost {
+		return
+	}
+
+	if err != nil {
+		af.fail(ctx, job, err, upstream)
+		return
+	}
+
+	err = af.audioFileStore.SaveResultASR(ctx, job.UUID.String(), job.ClaimID, results, upstream)
+	if released(job, err) {
+		return
+	}
+
+	if err != nil {
+		log.Errorf("error in writing the ASR result. error: %v", err)
+		if err := af.audioFileStore.FailASR(ctx, job.UUID.String(), job.ClaimID, StatusINVALID, err.Error(), upstream); err != nil && !released(job, err) {
+			log.Errorf("error in updating ASR job status. error: %v", err)
+		}
+	}
+}
+
+// released сообщает, что задание больше не принадлежит попытке: пока оно распознавалось, администратор сменил его статус
+// или задание вернули в очередь по истечении аренды. Результат попытки в этом случае отбрасывается
+func released(job AudioFile, err error) bool {
+
+	var errConflict *database.ConflictError
+	if !errors.As(err, &errConflict) {
+		return false
+	}
+
+	log.Warnf("ASR job %v was changed by the administrator or returned to the queue while processing, the result of attempt %d is discarded", job.UUID, job.Attempts)
+
+	return true
+}
+
+// fail планирует повтор задания по политике ASR, а исчерпавшее попытки задание переводит в FAILED.
+// upstream — время, которое неудачная попытка ждала ответа ASR
+func (af *AudioFiles) fail(ctx context.Context, job AudioFile, jobErr error, upstream time.Duration) {
+
+	policy := af.cfg.Retry[job.ASR]
+
+	var err error
+
+	switch {
+	case !retryable(jobErr, policy):
+		log.Errorf("error in ASR job %v. error: %v", job.UUID, jobErr)
+		err = af.audioFileStore.FailASR(ctx, job.UUID.String(), job.ClaimID, StatusINVALID, jobErr.Error(), upstream)
+	case job.Attempts >= policy.MaxAttempts:
+		log.Errorf("ASR job %v failed after %d attempts. error: %v", job.UUID, job.Attempts, jobErr)
+		err = af.audioFileStore.FailASR(ctx, job.UUID.String(), job.ClaimID, StatusFAILED, jobErr.Error(), upstream)
+	default:
+		delay := backoff(policy, job.Attempts)
+		log.Infof("ASR job %v attempt %d failed, retry in %v. error: %v", job.UUID, job.Attempts, delay, jobErr)
+		err = af.audioFileStore.RetryASR(ctx, job.UUID.String(), job.ClaimID, jobErr.Error(), upstream, time.Now().Add(delay))
+	}
+
+	if err != nil && !released(job, err) {
+		log.Errorf("error in updating ASR job status. error: %v", err)
+	}
+}
+
+func (af *AudioFiles) runJob(ctx context.Context, job AudioFile) ([]ResultASR, time.Duration, error) {
+
+	service, ok := af.asrRegistry.GetService(job.ASR)
+	if !ok {
+		return nil, 0, errUnknownASR
+	}
+
+	file, err := af.fileStorage.Get(ctx, job.StorageKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	job.Data = data
+
+	return af.recognize(ctx, service, job)
+}
@@ -0,0 +1,319 @@
+package audiofilesapp_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/wav"
+	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/RecoBattle/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const jobUUID = "2d53b244-8844-40a6-ab37-e5b89019af0a"
+
+const claimID = "8b1f6c2e-7d4a-4e9b-a3c5-1f2e3d4c5b6a"
+
+type fakeASR struct {
+	transcript *asr.Transcript
+	err        error
+	audio      asr.Audio
+	delay      time.Duration
+}
+
+func (f *fakeASR) Requirements() asr.Requirements {
+	return asr.Requirements{Format: asr.FormatLPCM, SampleRateHertz: 8000, BitsPerSample: 16, Channels: 1}
+}
+
+func (f *fakeASR) Recognize(_ context.Context, audio asr.Audio, _ asr.Options) (*asr.Transcript, error) {
+	f.audio = audio
+	time.Sleep(f.delay)
+	return f.transcript, f.err
+}
+
+var queueConfig = config.Queue{
+	Workers:  2,
+	Instance: "worker-1",
+	Retry: map[string]config.RetryPolicy{
+		"fake": {MaxAttempts: 3, InitialBackoff: 2, MaxBackoff: 60, Multiplier: 2, RetryableStatusCodes: []int{503}},
+	},
+}
+
+func newApp(t *testing.T, store *mocks.MockAudioFileStore, service asr.ASR) *audiofilesapp.AudioFiles {
+	return newAppWithQueue(t, store, service, queueConfig)
+}
+
+func newAppWithQueue(t *testing.T, store *mocks.MockAudioFileStore, service asr.ASR, queue config.Queue) *audiofilesapp.AudioFiles {
+
+	dir := t.TempDir() + string(filepath.Separator)
+	// стерео 16 кГц, 0.1 секунды
+	stereo := wav.Encode(wav.Info{SampleRateHertz: 16000, BitsPerSample: 16, Channels: 2}, make([]byte, 1600*4))
+	if err := os.WriteFile(dir+"rec.wav", stereo, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(dir+"bad.wav", []byte("RIFF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := asr.ASRRegistry{Services: make(map[string]asr.ASR)}
+	registry.AddService("fake", service)
+
+	fileStorage, err := storage.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return audiofilesapp.NewAudioFile(store, &registry, fileStorage, queue, config.Quota{}, nil)
+}
+
+func job() *audiofilesapp.AudioFile {
+	return &audiofilesapp.AudioFile{
+		UUID:       uuid.MustParse(jobUUID),
+		FileID:     "file",
+		FileName:   "rec.wav",
+		StorageKey: "rec.wav",
+		ASR:        "fake",
+		Status:     audiofilesapp.StatusPROCESSING,
+		Attempts:   1,
+		ClaimID:    claimID,
+	}
+}
+
+func TestAudioFiles_ProcessNext(t *testing.T) {
+
+	t.Run("Empty queue", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return((*audiofilesapp.AudioFile)(nil), nil)
+
+		assert.False(t, newApp(t, store, &fakeASR{}).ProcessNext(context.Background()))
+	})
+
+	t.Run("Processed", func(t *testing.T) {
+
+		service := &fakeASR{transcript: &asr.Transcript{
+			Text:     "добрый день",
+			Segments: []asr.Segment{{ChannelTag: "1", Text: "добрый день", StartTime: 0.5, EndTime: 1.2, Confidence: 0.9}},
+		}}
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil)
+		// каналы стерео-записи распознаются отдельно и получают свой channelTag
+		store.On("SaveResultASR", mock.Anything, jobUUID, claimID, []audiofilesapp.ResultASR{{
+			UUID:       uuid.MustParse(jobUUID),
+			ChannelTag: "1",
+			Text:       "добрый день",
+			StartTime:  0.5,
+			EndTime:    1.2,
+			Confidence: 0.9,
+		}, {
+			UUID:       uuid.MustParse(jobUUID),
+			ChannelTag: "2",
+			Text:       "добрый день",
+			StartTime:  0.5,
+			EndTime:    1.2,
+			Confidence: 0.9,
+		}}, mock.Anything).Return(nil)
+
+		assert.True(t, newApp(t, store, service).ProcessNext(context.Background()))
+		assert.Equal(t, asr.FormatLPCM, service.audio.Format)
+		assert.Equal(t, 8000, service.audio.SampleRateHertz)
+		assert.Equal(t, 1, service.audio.Channels)
+		assert.Len(t, service.audio.Data, 800*2)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Upstream latency", func(t *testing.T) {
+
+		service := &fakeASR{transcript: &asr.Transcript{}, delay: 20 * time.Millisecond}
+
+		// оба канала стерео-записи отправляются в ASR, задержки складываются
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil)
+		store.On("SaveResultASR", mock.Anything, jobUUID, claimID, mock.Anything, mock.MatchedBy(func(upstream time.Duration) bool {
+			return upstream >= 40*time.Millisecond
+		})).Return(nil)
+
+		assert.True(t, newApp(t, store, service).ProcessNext(context.Background()))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Invalid audio", func(t *testing.T) {
+
+		bad := job()
+		bad.StorageKey = "bad.wav"
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(bad, nil)
+		// файл не дошёл до ASR, замера времени ответа нет
+		store.On("FailASR", mock.Anything, jobUUID, claimID, audiofilesapp.StatusINVALID, mock.Anything, time.Duration(0)).Return(nil)
+
+		service := &fakeASR{}
+		assert.True(t, newApp(t, store, service).ProcessNext(context.Background()))
+		assert.Nil(t, service.audio.Data)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("ASR error", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil)
+		// время, которое неудачная попытка ждала ответа ASR, сохраняется
+		store.On("FailASR", mock.Anything, jobUUID, claimID, audiofilesapp.StatusINVALID, "bad audio", mock.MatchedBy(func(upstream time.Duration) bool {
+			return upstream >= 20*time.Millisecond
+		})).Return(nil)
+
+		assert.True(t, newApp(t, store, &fakeASR{err: errors.New("bad audio"), delay: 20 * time.Millisecond}).ProcessNext(context.Background()))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Retryable ASR error", func(t *testing.T) {
+
+		statusErr := asr.NewStatusError("fake", 503, "overloaded")
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil)
+		store.On("RetryASR", mock.Anything, jobUUID, claimID, statusErr.Error(), mock.MatchedBy(func(upstream time.Duration) bool {
+			return upstream >= 20*time.Millisecond
+		}), mock.MatchedBy(func(next time.Time) bool {
+			return next.After(time.Now().Add(time.Second)) && next.Before(time.Now().Add(3*time.Second))
+		})).Return(nil)
+
+		assert.True(t, newApp(t, store, &fakeASR{err: statusErr, delay: 20 * time.Millisecond}).ProcessNext(context.Background()))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Changed by the administrator", func(t *testing.T) {
+
+		// пока задание распознавалось, администратор перевёл его в FAILED: результат попытки отбрасывается
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil)
+		store.On("SaveResultASR", mock.Anything, jobUUID, claimID, mock.Anything, mock.Anything).Return(database.NewErrorConflict(errors.New(jobUUID)))
+
+		assert.True(t, newApp(t, store, &fakeASR{transcript: &asr.Transcript{}}).ProcessNext(context.Background()))
+		store.AssertExpectations(t)
+		store.AssertNotCalled(t, "FailASR", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Retries exhausted", func(t *testing.T) {
+
+		statusErr := asr.NewStatusError("fake", 503, "overloaded")
+
+		exhausted := job()
+		exhausted.Attempts = 3
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(exhausted, nil)
+		store.On("FailASR", mock.Anything, jobUUID, claimID, audiofilesapp.StatusFAILED, statusErr.Error(), mock.Anything).Return(nil)
+
+		assert.True(t, newApp(t, store, &fakeASR{err: statusErr}).ProcessNext(context.Background()))
+		store.AssertExpectations(t)
+	})
+}
+
+func TestAudioFiles_RunWorkers(t *testing.T) {
+
+	store := new(mocks.MockAudioFileStore)
+	store.On("ResumeASR", mock.Anything, "worker-1").Return(int64(1), nil)
+	// аренда по умолчанию — 5 минут, задания всех экземпляров старше неё возвращаются в очередь
+	store.On("ReleaseStaleASR", mock.Anything, mock.MatchedBy(func(staleBefore time.Time) bool {
+		return staleBefore.Before(time.Now().Add(-4*time.Minute)) && staleBefore.After(time.Now().Add(-6*time.Minute))
+	})).Return(int64(0), nil)
+	store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil).Once()
+	store.On("ClaimASR", mock.Anything, "worker-1").Return((*audiofilesapp.AudioFile)(nil), nil)
+	saved := make(chan struct{})
+	store.On("SaveResultASR", mock.Anything, jobUUID, claimID, mock.Anything, mock.Anything).Return(nil).Run(func(mock.Arguments) { close(saved) })
+
+	app := newApp(t, store, &fakeASR{transcript: &asr.Transcript{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		app.RunWorkers(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-saved:
+	case <-time.After(time.Second):
+		t.Error("job was not processed")
+	}
+
+	cancel()
+	<-done
+
+	store.AssertCalled(t, "ResumeASR", mock.Anything, "worker-1")
+	store.AssertCalled(t, "ReleaseStaleASR", mock.Anything, mock.Anything)
+	store.AssertCalled(t, "SaveResultASR", mock.Anything, jobUUID, claimID, mock.Anything, mock.Anything)
+}
+
+func TestAudioFiles_RunWorkersInstance(t *testing.T) {
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	store := new(mocks.MockAudioFileStore)
+	// по умолчанию задания помечаются именем хоста
+	store.On("ResumeASR", mock.Anything, hostname).Return(int64(0), nil)
+	store.On("ReleaseStaleASR", mock.Anything, mock.Anything).Return(int64(0), nil)
+
+	registry := asr.ASRRegistry{Services: make(map[string]asr.ASR)}
+	app := audiofilesapp.NewAudioFile(store, &registry, nil, config.Queue{Workers: 1}, config.Quota{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	app.RunWorkers(ctx)
+
+	store.AssertExpectations(t)
+}
+
+func TestAudioFiles_ProcessLease(t *testing.T) {
+
+	// аренда в 1 секунду продлевается примерно каждые 333 мс, ASR отвечает за 500 мс
+	queue := queueConfig
+	queue.Lease = 1
+
+	t.Run("Extended", func(t *testing.T) {
+
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil)
+		store.On("HeartbeatASR", mock.Anything, jobUUID, claimID).Return(nil)
+		store.On("SaveResultASR", mock.Anything, jobUUID, claimID, mock.Anything, mock.Anything).Return(nil)
+
+		service := &fakeASR{transcript: &asr.Transcript{}, delay: 250 * time.Millisecond}
+
+		assert.True(t, newAppWithQueue(t, store, service, queue).ProcessNext(context.Background()))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Lost", func(t *testing.T) {
+
+		// пока файл распознавался, задание вернули в очередь: попытка ничего не записывает
+		store := new(mocks.MockAudioFileStore)
+		store.On("ClaimASR", mock.Anything, "worker-1").Return(job(), nil)
+		store.On("HeartbeatASR", mock.Anything, jobUUID, claimID).Return(database.NewErrorConflict(errors.New(jobUUID)))
+
+		service := &fakeASR{transcript: &asr.Transcript{}, delay: 500 * time.Millisecond}
+
+		assert.True(t, newAppWithQueue(t, store, service, queue).ProcessNext(context.Background()))
+		store.AssertExpectations(t)
+		store.AssertNotCalled(t, "SaveResultASR", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		store.AssertNotCalled(t, "RetryASR", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		store.AssertNotCalled(t, "FailASR", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,86 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/RecoBattle/cmd/config"
+)
+
+const (
+	// RoundUp неполная единица тарифицируется целиком
+	RoundUp = "up"
+	// RoundNearest неполная единица округляется до ближайшей целой
+	RoundNearest = "nearest"
+	// RoundNone тарифицируется точная доля единицы, например посекундно при цене за минуту
+	RoundNone = "none"
+)
+
+// ErrInvalid тариф в конфиге задан неверно
+var ErrInvalid = errors.New("invalid pricing")
+
+type Cost struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// Prices тарифы ASR. Стоимость считается по текущим тарифам, поэтому после их изменения пересчитываются и старые задания
+type Prices struct {
+	prices map[string]config.Pricing
+}
+
+// New проверяет тарифы из конфига. Rounding по умолчанию up, Unit по умолчанию одна секунда
+func New(cfg map[string]config.Pricing) (*Prices, error) {
+
+	prices := make(map[string]config.Pricing, len(cfg))
+
+	for name, price := range cfg {
+
+		if price.Rounding == "" {
+			price.Rounding = RoundUp
+		}
+
+		if price.Unit == 0 {
+			price.Unit = 1
+		}
+
+		switch {
+		case price.Price < 0:
+			return nil, fmt.Errorf("%w: negative price for %s", ErrInvalid, name)
+		case price.Rounding != RoundUp && price.Rounding != RoundNearest && price.Rounding != RoundNone:
+			return nil, fmt.Errorf("%w: unknown rounding %q for %s", ErrInvalid, price.Rounding, name)
+		}
+
+		prices[name] = price
+	}
+
+	return &Prices{prices: prices}, nil
+}
+
+// Cost стоимость распознавания seconds секунд аудио сервисом asrName; false, если тариф для ASR не задан
+func (p *Prices) Cost(asrName string, seconds float64) (*Cost, bool) {
+
+	if p == nil {
+		return nil, false
+	}
+
+	price, ok := p.prices[asrName]
+	if !ok {
+		return nil, false
+	}
+
+	units := seconds / float64(price.Unit)
+
+	switch price.Rounding {
+	case RoundUp:
+		// длительность вычислена из размера данных, поэтому отбрасываем погрешность вычислений
+		units = math.Ceil(units - 1e-9)
+	case RoundNearest:
+		units = math.Round(units)
+	}
+
+	units = max(units, float64(price.MinUnits))
+
+	return &Cost{Amount: math.Round(units*price.Price*1e4) / 1e4, Currency: price.Currency}, true
+}
@@ -0,0 +1,55 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrices_Cost(t *testing.T) {
+
+	prices, err := New(map[string]config.Pricing{
+		"blocks":  {Currency: "RUB", Unit: 15, Price: 0.16, MinUnits: 1},
+		"minutes": {Currency: "USD", Unit: 60, Price: 0.006, Rounding: RoundNone},
+		"nearest": {Currency: "USD", Unit: 60, Price: 0.01, Rounding: RoundNearest},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		asr     string
+		seconds float64
+		amount  float64
+	}{
+		{"blocks", 15, 0.16},
+		{"blocks", 15.5, 0.32},
+		{"blocks", 0.2, 0.16},
+		{"blocks", 0, 0.16},
+		{"minutes", 90, 0.009},
+		{"nearest", 89, 0.01},
+		{"nearest", 91, 0.02},
+	}
+
+	for _, tc := range tests {
+		cost, ok := prices.Cost(tc.asr, tc.seconds)
+		if assert.True(t, ok, tc.asr) {
+			assert.InDelta(t, tc.amount, cost.Amount, 1e-9, "%s %v", tc.asr, tc.seconds)
+		}
+	}
+
+	_, ok := prices.Cost("unknown", 60)
+	assert.False(t, ok)
+
+	_, ok = (*Prices)(nil).Cost("blocks", 60)
+	assert.False(t, ok)
+}
+
+func TestNew_Invalid(t *testing.T) {
+
+	_, err := New(map[string]config.Pricing{"asr": {Unit: 60, Price: 1, Rounding: "down"}})
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = New(map[string]config.Pricing{"asr": {Unit: 60, Price: -1}})
+	assert.ErrorIs(t, err, ErrInvalid)
+}
@@ -0,0 +1,174 @@
+package qualitycontrolapp
+
+import (
+	"strings"
+)
+
+const (
+	OpMatch        = "match"
+	OpSubstitution = "substitution"
+	OpInsertion    = "insertion"
+	OpDeletion     = "deletion"
+)
+
+type ErrorRate struct {
+	Rate          float32 `json:"rate"`
+	Hits          int     `json:"hits"`
+	Substitutions int     `json:"substitutions"`
+	Insertions    int     `json:"insertions"`
+	Deletions     int     `json:"deletions"`
+	Reference     int     `json:"reference"`
+}
+
+type AlignedToken struct {
+	Op    string `json:"op"`
+	Ideal string `json:"ideal,omitempty"`
+	ASR   string `json:"asr,omitempty"`
+}
+
+type editOp struct {
+	op  string
+	ref int
+	hyp int
+}
+
+// wordErrorRate считает WER по словам нормализованных текстов
+func wordErrorRate(ideal, asr string) ErrorRate {
+
+	ref := strings.Fields(ideal)
+	hyp := strings.Fields(asr)
+
+	return errorRate(align(ref, hyp), len(ref))
+}
+
+// charErrorRate считает CER по символам нормализованных текстов, пробелы между словами учитываются
+func charErrorRate(ideal, asr string) ErrorRate {
+
+	ref := []rune(strings.Join(strings.Fields(ideal), " "))
+	hyp := []rune(strings.Join(strings.Fields(asr), " "))
+
+	return errorRate(align(ref, hyp), len(ref))
+}
+
+// alignWords выравнивает слова эталонного текста и результата ASR
+func alignWords(ideal, asr string) ([]AlignedToken, ErrorRate) {
+
+	ref := strings.Fields(ideal)
+	hyp := strings.Fields(asr)
+
+	ops := align(ref, hyp)
+	tokens := make([]AlignedToken, 0, len(ops))
+
+	for _, op := range ops {
+		token := AlignedToken{Op: op.op}
+		if op.ref >= 0 {
+			token.Ideal = ref[op.ref]
+		}
+		if op.hyp >= 0 {
+			token.ASR = hyp[op.hyp]
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, errorRate(ops, len(ref))
+}
+
+func errorRate(ops []editOp, reference int) ErrorRate {
+
+	er := ErrorRate{Reference: reference}
+
+	for _, op := range ops {
+		switch op.op {
+		case OpMatch:
+			er.Hits++
+		case OpSubstitution:
+			er.Substitutions++
+		case OpInsertion:
+			er.Insertions++
+		case OpDeletion:
+			er.Deletions++
+		}
+	}
+
+	wrong := er.Substitutions + er.Insertions + er.Deletions
+
+	switch {
+	case reference > 0:
+		er.Rate = float32(wrong) / float32(reference)
+	case wrong > 0:
+		er.Rate = 1
+	}
+
+	return er
+}
+
+// align выравнивает две последовательности по расстоянию Левенштейна и возвращает цепочку операций.
+// Из путей с одинаковым числом ошибок выбирается путь с наибольшим числом совпадений.
+func align[T comparable](ref, hyp []T) []editOp {
+
+	rows, cols := len(ref)+1, len(hyp)+1
+
+	dist := make([][]int, rows)
+	hits := make([][]int, rows)
+	for i := range dist {
+		dist[i] = make([]int, cols)
+		hits[i] = make([]int, cols)
+		dist[i][0] = i
+	}
+
+	for j := 0; j < cols; j++ {
+		dist[0][j] = j
+	}
+
+	better := func(d, h, bestD, bestH int) bool {
+		return d < bestD || (d == bestD && h > bestH)
+	}
+
+	for i := 1; i < rows; i++ {
+		for j := 1; j < cols; j++ {
+
+			d, h := dist[i-1][j-1]+1, hits[i-1][j-1]
+			if ref[i-1] == hyp[j-1] {
+				d, h = dist[i-1][j-1], hits[i-1][j-1]+1
+			}
+
+			if better(dist[i-1][j]+1, hits[i-1][j], d, h) {
+				d, h = dist[i-1][j]+1, hits[i-1][j]
+			}
+
+			if better(dist[i][j-1]+1, hits[i][j-1], d, h) {
+				d, h = dist[i][j-1]+1, hits[i][j-1]
+			}
+
+			dist[i][j], hits[i][j] = d, h
+		}
+	}
+
+	ops := make([]editOp, 0, max(len(ref), len(hyp)))
+
+	i, j := len(ref), len(hyp)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && ref[i-1] == hyp[j-1] && dist[i][j] == dist[i-1][j-1] && hits[i][j] == hits[i-1][j-1]+1:
+			ops = append(ops, editOp{op: OpMatch, ref: i - 1, hyp: j - 1})
+			i--
+			j--
+		case i > 0 && j > 0 && ref[i-1] != hyp[j-1] && dist[i][j] == dist[i-1][j-1]+1 && hits[i][j] == hits[i-1][j-1]:
+			ops = append(ops, editOp{op: OpSubstitution, ref: i - 1, hyp: j - 1})
+			i--
+			j--
+		case i > 0 && dist[i][j] == dist[i-1][j]+1 && hits[i][j] == hits[i-1][j]:
+			ops = append(ops, editOp{op: OpDeletion, ref: i - 1, hyp: -1})
+			i--
+		default:
+			ops = append(ops, editOp{op: OpInsertion, ref: -1, hyp: j - 1})
+			j--
+		}
+	}
+
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+
+	return ops
+}
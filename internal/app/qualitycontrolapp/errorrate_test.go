@@ -0,0 +1,68 @@
+package qualitycontrolapp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordErrorRate(t *testing.T) {
+
+	t.Run("Identical", func(t *testing.T) {
+
+		er := wordErrorRate("добрый день у меня вопрос", "добрый день у меня вопрос")
+		assert.Equal(t, ErrorRate{Rate: 0, Hits: 5, Reference: 5}, er)
+	})
+
+	t.Run("Inserted word does not shift the rest", func(t *testing.T) {
+
+		er := wordErrorRate("добрый день у меня вопрос", "ну добрый день у меня вопрос")
+		assert.Equal(t, 1, er.Insertions)
+		assert.Equal(t, 5, er.Hits)
+		assert.InDelta(t, 0.2, er.Rate, 1e-6)
+	})
+
+	t.Run("Deleted word", func(t *testing.T) {
+
+		er := wordErrorRate("добрый день у меня вопрос", "день у меня вопрос")
+		assert.Equal(t, 1, er.Deletions)
+		assert.Equal(t, 0, er.Substitutions)
+		assert.InDelta(t, 0.2, er.Rate, 1e-6)
+	})
+
+	t.Run("Substitution", func(t *testing.T) {
+
+		er := wordErrorRate("добрый день", "добрый вечер")
+		assert.Equal(t, ErrorRate{Rate: 0.5, Hits: 1, Substitutions: 1, Reference: 2}, er)
+	})
+
+	t.Run("Empty reference", func(t *testing.T) {
+
+		assert.Equal(t, float32(1), wordErrorRate("", "шум").Rate)
+		assert.Equal(t, float32(0), wordErrorRate("", "").Rate)
+	})
+}
+
+func TestCharErrorRate(t *testing.T) {
+
+	er := charErrorRate("кот", "кит")
+	assert.Equal(t, ErrorRate{Rate: float32(1) / 3, Hits: 2, Substitutions: 1, Reference: 3}, er)
+
+	er = charErrorRate("да  нет", "да нет")
+	assert.Equal(t, float32(0), er.Rate)
+}
+
+func TestAlignWords(t *testing.T) {
+
+	tokens, wer := alignWords("добрый день у меня вопрос", "добрый вечер у вопрос да")
+
+	assert.Equal(t, []AlignedToken{
+		{Op: OpMatch, Ideal: "добрый", ASR: "добрый"},
+		{Op: OpSubstitution, Ideal: "день", ASR: "вечер"},
+		{Op: OpMatch, Ideal: "у", ASR: "у"},
+		{Op: OpDeletion, Ideal: "меня"},
+		{Op: OpMatch, Ideal: "вопрос", ASR: "вопрос"},
+		{Op: OpInsertion, ASR: "да"},
+	}, tokens)
+	assert.Equal(t, ErrorRate{Rate: 0.6, Hits: 3, Substitutions: 1, Insertions: 1, Deletions: 1, Reference: 5}, wer)
+}
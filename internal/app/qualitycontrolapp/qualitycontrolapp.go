@@ -2,9 +2,15 @@ package qualitycontrolapp
 
 import (
 	"context"
+	"errors"
+	"math"
+	"sort"
 	"strings"
 	"unicode"
 
+	"github.com/RecoBattle/internal/app/pricing"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/database"
 	"github.com/google/uuid"
 )
 
@@ -16,28 +22,65 @@ type IdealText struct {
 }
 
 type QualityControl struct {
-	ASR       string  `json:"asr"`
-	TestIdeal string  `json:"-"`
-	TextASR   string  `json:"-"`
-	Quality   float32 `json:"quality"`
+	JobUUID    string    `json:"-"`
+	ASR        string    `json:"asr"`
+	ChannelTag string    `json:"channelTag"`
+	TestIdeal  string    `json:"-"`
+	TextASR    string    `json:"-"`
+	Quality    float32   `json:"quality"`
+	WER        ErrorRate `json:"wer"`
+	CER        ErrorRate `json:"cer"`
+}
+
+type Diff struct {
+	ASR        string         `json:"asr"`
+	ChannelTag string         `json:"channelTag"`
+	WER        ErrorRate      `json:"wer"`
+	Tokens     []AlignedToken `json:"tokens"`
+}
+
+// FileTexts эталонные тексты и результаты ASR по каналам одного файла
+type FileTexts struct {
+	FileID     string
+	Duration   float64
+	IdealTexts map[string]string
+	Results    []QualityControl
+}
+
+// ASRReport качество и стоимость распознавания набора файлов одним ASR
+type ASRReport struct {
+	ASR          string        `json:"asr"`
+	Files        int           `json:"files"`
+	AudioMinutes float64       `json:"audio_minutes"`
+	MeanWER      float32       `json:"mean_wer"`
+	Cost         *pricing.Cost `json:"cost,omitempty"`
 }
 
 type QualityControlStore interface {
+	GetFileOwner(ctx context.Context, fileID string) (userID, workspaceID string, err error)
 	Create(ctx context.Context, qualityControl IdealText) error
-	GetTextASRIdeal(ctx context.Context, fileID string) ([]QualityControl, string, error)
+	GetTextASRIdeal(ctx context.Context, fileID string) ([]QualityControl, map[string]string, error)
+	GetFileTexts(ctx context.Context, scope workspaceapp.Scope, fileIDs []string) ([]FileTexts, error)
 }
 
 type QualityControls struct {
 	QualityControlStore QualityControlStore
+	prices              *pricing.Prices
 }
 
-func NewQualityControl(qualityControlStore QualityControlStore) *QualityControls {
+func NewQualityControl(qualityControlStore QualityControlStore, prices *pricing.Prices) *QualityControls {
 	return &QualityControls{
 		QualityControlStore: qualityControlStore,
+		prices:              prices,
 	}
 }
 
-func (qc *QualityControls) Create(ctx context.Context, qualityControl IdealText) error {
+// Create сохраняет эталонный текст канала. Файл должен относиться к пространству, иначе NotFoundError
+func (qc *QualityControls) Create(ctx context.Context, scope workspaceapp.Scope, qualityControl IdealText) error {
+
+	if err := qc.checkOwner(ctx, scope, qualityControl.FileID); err != nil {
+		return err
+	}
 
 	qualityControl.UUID = uuid.New()
 
@@ -48,57 +91,194 @@ func (qc *QualityControls) Create(ctx context.Context, qualityControl IdealText)
 	return nil
 }
 
-func (qc *QualityControls) QualityControl(ctx context.Context, fileID string) (*[]QualityControl, error) {
+func (qc *QualityControls) QualityControl(ctx context.Context, scope workspaceapp.Scope, fileID string) (*[]QualityControl, error) {
 
-	data, idealText, err := qc.QualityControlStore.GetTextASRIdeal(ctx, fileID)
+	data, idealTexts, err := qc.textASRIdeal(ctx, scope, fileID)
 	if err != nil {
 		return nil, err
 	}
 
-	idealText = removeSpecialCharacters(idealText)
+	data = byChannel(data, idealTexts)
 
 	for i := range data {
+		idealText := removeSpecialCharacters(data[i].TestIdeal)
 		resASR := removeSpecialCharacters(data[i].TextASR)
-		data[i].Quality = compareStrings(idealText, resASR)
+		data[i].WER = wordErrorRate(idealText, resASR)
+		data[i].CER = charErrorRate(idealText, resASR)
+		data[i].Quality = max(0, 1-data[i].WER.Rate)
 		data[i].TestIdeal = idealText
 	}
 
 	return &data, nil
 }
 
-func removeSpecialCharacters(s string) string {
+func (qc *QualityControls) Diff(ctx context.Context, scope workspaceapp.Scope, fileID string) (*[]Diff, error) {
 
-	var cleanedString strings.Builder
+	data, idealTexts, err := qc.textASRIdeal(ctx, scope, fileID)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, r := range s {
-		if unicode.IsLetter(r) || unicode.IsSpace(r) {
-			cleanedString.WriteRune(r)
+	data = byChannel(data, idealTexts)
+
+	diffs := make([]Diff, 0, len(data))
+
+	for i := range data {
+		tokens, wer := alignWords(removeSpecialCharacters(data[i].TestIdeal), removeSpecialCharacters(data[i].TextASR))
+		diffs = append(diffs, Diff{ASR: data[i].ASR, ChannelTag: data[i].ChannelTag, WER: wer, Tokens: tokens})
+	}
+
+	return &diffs, nil
+}
+
+// Report сравнивает ASR на файлах пространства с эталонными текстами: средний по файлам WER и суммарная стоимость распознавания этих файлов.
+// Пустой fileIDs — все такие файлы пространства. Файл учитывается для ASR, только если тот его распознал
+func (qc *QualityControls) Report(ctx context.Context, scope workspaceapp.Scope, fileIDs []string) ([]ASRReport, error) {
+
+	files, err := qc.QualityControlStore.GetFileTexts(ctx, scope, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make(map[string]*ASRReport)
+
+	var (
+		werSum  = make(map[string]float32)
+		seconds = make(map[string]float64)
+	)
+
+	for _, file := range files {
+
+		// WER файла считается по всем его каналам вместе
+		wrong := make(map[string]int)
+		reference := make(map[string]int)
+
+		for _, d := range byChannel(file.Results, file.IdealTexts) {
+			wer := wordErrorRate(removeSpecialCharacters(d.TestIdeal), removeSpecialCharacters(d.TextASR))
+			wrong[d.ASR] += wer.Substitutions + wer.Insertions + wer.Deletions
+			reference[d.ASR] += wer.Reference
+		}
+
+		for name := range wrong {
+
+			report, ok := reports[name]
+			if !ok {
+				report = &ASRReport{ASR: name}
+				reports[name] = report
+			}
+
+			report.Files++
+			seconds[name] += file.Duration
+
+			switch {
+			case reference[name] > 0:
+				werSum[name] += float32(wrong[name]) / float32(reference[name])
+			case wrong[name] > 0:
+				werSum[name]++
+			}
+
+			// тариф применяется к каждому заданию отдельно, поэтому стоимость складывается по файлам
+			if cost, ok := qc.prices.Cost(name, file.Duration); ok {
+				if report.Cost == nil {
+					report.Cost = &pricing.Cost{Currency: cost.Currency}
+				}
+				report.Cost.Amount += cost.Amount
+			}
 		}
 	}
 
-	return strings.ToLower(cleanedString.String())
+	result := make([]ASRReport, 0, len(reports))
+
+	for name, report := range reports {
+
+		report.MeanWER = werSum[name] / float32(report.Files)
+		report.AudioMinutes = math.Round(seconds[name]/60*100) / 100
+
+		if report.Cost != nil {
+			report.Cost.Amount = math.Round(report.Cost.Amount*1e4) / 1e4
+		}
+
+		result = append(result, *report)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ASR < result[j].ASR })
+
+	return result, nil
 }
 
-func compareStrings(str1, str2 string) float32 {
+// textASRIdeal результаты ASR и эталонные тексты файла пространства
+func (qc *QualityControls) textASRIdeal(ctx context.Context, scope workspaceapp.Scope, fileID string) ([]QualityControl, map[string]string, error) {
 
-	words1 := strings.Fields(str1)
-	words2 := strings.Fields(str2)
+	if err := qc.checkOwner(ctx, scope, fileID); err != nil {
+		return nil, nil, err
+	}
+
+	return qc.QualityControlStore.GetTextASRIdeal(ctx, fileID)
+}
 
-	minLength := len(words1)
+// checkOwner проверяет, что файл относится к пространству. Чужой файл не отличается от отсутствующего — NotFoundError
+func (qc *QualityControls) checkOwner(ctx context.Context, scope workspaceapp.Scope, fileID string) error {
+
+	userID, workspaceID, err := qc.QualityControlStore.GetFileOwner(ctx, fileID)
+	if err != nil {
+		return err
+	}
 
-	if len(words2) < minLength {
-		minLength = len(words2)
+	if !scope.Contains(userID, workspaceID) {
+		return database.NewErrorNotFound(errors.New(fileID))
 	}
 
-	matches := 0
+	return nil
+}
+
+// byChannel сопоставляет каждому каналу с эталонным текстом результат задания ASR по тому же каналу.
+// Если ASR ничего не распознал в канале, эталон сравнивается с пустым текстом; каналы без эталона не оцениваются.
+// Результаты группируются по заданию, чтобы каналы разных запусков одного ASR не смешивались.
+func byChannel(data []QualityControl, idealTexts map[string]string) []QualityControl {
 
-	for i := 0; i < minLength; i++ {
-		if words1[i] == words2[i] {
-			matches++
+	channels := make([]string, 0, len(idealTexts))
+	for channelTag := range idealTexts {
+		channels = append(channels, channelTag)
+	}
+	sort.Strings(channels)
+
+	var jobs []QualityControl
+	texts := make(map[string]map[string]string)
+
+	for _, d := range data {
+		if _, ok := texts[d.JobUUID]; !ok {
+			jobs = append(jobs, d)
+			texts[d.JobUUID] = make(map[string]string)
+		}
+		texts[d.JobUUID][d.ChannelTag] = d.TextASR
+	}
+
+	result := make([]QualityControl, 0, len(jobs)*len(channels))
+
+	for _, job := range jobs {
+		for _, channelTag := range channels {
+			result = append(result, QualityControl{
+				JobUUID:    job.JobUUID,
+				ASR:        job.ASR,
+				ChannelTag: channelTag,
+				TestIdeal:  idealTexts[channelTag],
+				TextASR:    texts[job.JobUUID][channelTag],
+			})
 		}
 	}
 
-	similarity := float32(matches) / float32(minLength)
+	return result
+}
+
+func removeSpecialCharacters(s string) string {
 
-	return similarity
+	var cleanedString strings.Builder
+
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			cleanedString.WriteRune(r)
+		}
+	}
+
+	return strings.ToLower(cleanedString.String())
 }
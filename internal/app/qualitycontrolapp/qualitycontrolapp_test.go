@@ -0,0 +1,190 @@
+package qualitycontrolapp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/pricing"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubStore struct {
+	data       []QualityControl
+	idealTexts map[string]string
+	files      []FileTexts
+	// владелец файла; по умолчанию личный файл пользователя "user"
+	userID, workspaceID string
+}
+
+func (s stubStore) GetFileOwner(context.Context, string) (string, string, error) {
+	if s.userID == "" && s.workspaceID == "" {
+		return "user", "", nil
+	}
+	return s.userID, s.workspaceID, nil
+}
+
+func (s stubStore) Create(context.Context, IdealText) error { return nil }
+
+func (s stubStore) GetTextASRIdeal(context.Context, string) ([]QualityControl, map[string]string, error) {
+	return s.data, s.idealTexts, nil
+}
+
+func (s stubStore) GetFileTexts(context.Context, workspaceapp.Scope, []string) ([]FileTexts, error) {
+	return s.files, nil
+}
+
+func TestQualityControls_QualityControlByChannel(t *testing.T) {
+
+	qc := NewQualityControl(stubStore{
+		data: []QualityControl{
+			{JobUUID: "vosk-1", ASR: "vosk", ChannelTag: "1", TextASR: "Добрый день, компания"},
+			{JobUUID: "vosk-1", ASR: "vosk", ChannelTag: "2", TextASR: "здравствуйте"},
+			{JobUUID: "whisper-1", ASR: "whisper", ChannelTag: "2", TextASR: "Здравствуйте!"},
+			{JobUUID: "whisper-1", ASR: "whisper", ChannelTag: "3", TextASR: "шум"},
+		},
+		idealTexts: map[string]string{
+			"1": "Добрый день, компания «Рога и копыта»",
+			"2": "Здравствуйте",
+		},
+	}, nil)
+
+	result, err := qc.QualityControl(context.Background(), workspaceapp.Personal("user"), "file")
+	require.NoError(t, err)
+	require.Len(t, *result, 4)
+
+	type score struct {
+		asr, channel string
+		wer          float32
+	}
+
+	var got []score
+	for _, r := range *result {
+		got = append(got, score{r.ASR, r.ChannelTag, r.WER.Rate})
+	}
+
+	assert.Equal(t, []score{
+		{"vosk", "1", 0.5},
+		{"vosk", "2", 0},
+		// whisper ничего не распознал в первом канале, а третьего канала нет в эталоне
+		{"whisper", "1", 1},
+		{"whisper", "2", 0},
+	}, got)
+}
+
+func TestQualityControls_QualityControlByJob(t *testing.T) {
+
+	// один и тот же ASR распознал файл дважды: каналы разных запусков не смешиваются
+	qc := NewQualityControl(stubStore{
+		data: []QualityControl{
+			{JobUUID: "first", ASR: "vosk", ChannelTag: "1", TextASR: "добрый"},
+			{JobUUID: "second", ASR: "vosk", ChannelTag: "2", TextASR: "здравствуйте"},
+			{JobUUID: "first", ASR: "vosk", ChannelTag: "2", TextASR: "привет"},
+		},
+		idealTexts: map[string]string{"1": "добрый", "2": "здравствуйте"},
+	}, nil)
+
+	result, err := qc.QualityControl(context.Background(), workspaceapp.Personal("user"), "file")
+	require.NoError(t, err)
+	require.Len(t, *result, 4)
+
+	type score struct {
+		job, channel string
+		wer          float32
+	}
+
+	var got []score
+	for _, r := range *result {
+		got = append(got, score{r.JobUUID, r.ChannelTag, r.WER.Rate})
+	}
+
+	assert.Equal(t, []score{
+		{"first", "1", 0},
+		{"first", "2", 1},
+		{"second", "1", 1},
+		{"second", "2", 0},
+	}, got)
+}
+
+func TestQualityControls_Owner(t *testing.T) {
+
+	data := []QualityControl{{JobUUID: "vosk-1", ASR: "vosk", ChannelTag: "1", TextASR: "добрый день"}}
+	idealTexts := map[string]string{"1": "добрый день"}
+
+	tests := []struct {
+		name                string
+		userID, workspaceID string
+		scope               workspaceapp.Scope
+		wantErr             bool
+	}{
+		{"Own personal file", "user", "", workspaceapp.Personal("user"), false},
+		{"Another user's personal file", "other", "", workspaceapp.Personal("user"), true},
+		// личный файл не виден из рабочего пространства, даже если его загрузил сам пользователь
+		{"Own personal file from workspace", "user", "", workspaceapp.Scope{UserID: "user", WorkspaceID: "ws"}, true},
+		{"File of the workspace", "other", "ws", workspaceapp.Scope{UserID: "user", WorkspaceID: "ws"}, false},
+		{"File of another workspace", "user", "ws-2", workspaceapp.Scope{UserID: "user", WorkspaceID: "ws"}, true},
+		{"Workspace file from personal space", "user", "ws", workspaceapp.Personal("user"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			qc := NewQualityControl(stubStore{data: data, idealTexts: idealTexts, userID: tt.userID, workspaceID: tt.workspaceID}, nil)
+
+			_, errQC := qc.QualityControl(context.Background(), tt.scope, "file")
+			_, errDiff := qc.Diff(context.Background(), tt.scope, "file")
+			errCreate := qc.Create(context.Background(), tt.scope, IdealText{FileID: "file", ChannelTag: "1", Text: "добрый день"})
+
+			for _, err := range []error{errQC, errDiff, errCreate} {
+				if tt.wantErr {
+					var notFound *database.NotFoundError
+					assert.ErrorAs(t, err, &notFound)
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+		})
+	}
+}
+
+func TestQualityControls_Report(t *testing.T) {
+
+	prices, err := pricing.New(map[string]config.Pricing{"cloud": {Currency: "RUB", Unit: 15, Price: 0.16}})
+	require.NoError(t, err)
+
+	qc := NewQualityControl(stubStore{
+		files: []FileTexts{
+			{
+				FileID:     "a",
+				Duration:   20,
+				IdealTexts: map[string]string{"1": "добрый день", "2": "здравствуйте"},
+				Results: []QualityControl{
+					{JobUUID: "cloud-a", ASR: "cloud", ChannelTag: "1", TextASR: "добрый день"},
+					{JobUUID: "cloud-a", ASR: "cloud", ChannelTag: "2", TextASR: "здравствуйте"},
+					{JobUUID: "vosk-a", ASR: "vosk", ChannelTag: "1", TextASR: "добрый"},
+				},
+			},
+			{
+				FileID:     "b",
+				Duration:   40,
+				IdealTexts: map[string]string{"1": "раз два три четыре"},
+				Results: []QualityControl{
+					{JobUUID: "cloud-b", ASR: "cloud", ChannelTag: "1", TextASR: "раз два три"},
+				},
+			},
+		},
+	}, prices)
+
+	report, err := qc.Report(context.Background(), workspaceapp.Personal("user"), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []ASRReport{
+		// файл a распознан без ошибок, в файле b пропущено одно слово из четырёх; 2 и 3 блока по 15 секунд
+		{ASR: "cloud", Files: 2, AudioMinutes: 1, MeanWER: 0.125, Cost: &pricing.Cost{Amount: 0.8, Currency: "RUB"}},
+		// ошибки обоих каналов файла a делятся на общее число слов эталона
+		{ASR: "vosk", Files: 1, AudioMinutes: 0.33, MeanWER: 0.6666667},
+	}, report)
+}
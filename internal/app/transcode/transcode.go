@@ -0,0 +1,241 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/RecoBattle/internal/app/wav"
+)
+
+// PCM хранит декодированное аудио: отдельный массив 16-битных отсчётов на каждый канал
+type PCM struct {
+	SampleRateHertz int
+	Channels        [][]int16
+}
+
+// Decode разбирает wav-файл и раскладывает отсчёты по каналам
+func Decode(data []byte) (*PCM, error) {
+
+	info, err := wav.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := info.PCM(data)
+	bytesPerSample := info.BitsPerSample / 8
+	frames := len(raw) / info.BlockAlign()
+
+	pcm := &PCM{SampleRateHertz: info.SampleRateHertz, Channels: make([][]int16, info.Channels)}
+	for ch := range pcm.Channels {
+		pcm.Channels[ch] = make([]int16, frames)
+	}
+
+	for i := 0; i < frames; i++ {
+		for ch := range pcm.Channels {
+			offset := (i*info.Channels + ch) * bytesPerSample
+			if bytesPerSample == 1 {
+				// 8-битный PCM в wav беззнаковый
+				pcm.Channels[ch][i] = int16(raw[offset]-128) << 8
+			} else {
+				pcm.Channels[ch][i] = int16(binary.LittleEndian.Uint16(raw[offset:]))
+			}
+		}
+	}
+
+	return pcm, nil
+}
+
+// Frames количество отсчётов в одном канале
+func (p *PCM) Frames() int {
+
+	if len(p.Channels) == 0 {
+		return 0
+	}
+
+	return len(p.Channels[0])
+}
+
+// Downmix сводит все каналы в один усреднением
+func (p *PCM) Downmix() *PCM {
+
+	if len(p.Channels) <= 1 {
+		return p
+	}
+
+	mono := make([]int16, p.Frames())
+	for i := range mono {
+		var sum int
+		for _, ch := range p.Channels {
+			sum += int(ch[i])
+		}
+		mono[i] = int16(sum / len(p.Channels))
+	}
+
+	return &PCM{SampleRateHertz: p.SampleRateHertz, Channels: [][]int16{mono}}
+}
+
+// Channel возвращает один канал как моно-запись
+func (p *PCM) Channel(n int) *PCM {
+	return &PCM{SampleRateHertz: p.SampleRateHertz, Channels: [][]int16{p.Channels[n]}}
+}
+
+// Resample меняет частоту дискретизации линейной интерполяцией.
+// При понижении частоты сигнал предварительно сглаживается скользящим средним, чтобы подавить наложение спектров.
+func (p *PCM) Resample(sampleRateHertz int) *PCM {
+
+	if sampleRateHertz <= 0 || sampleRateHertz == p.SampleRateHertz {
+		return p
+	}
+
+	ratio := float64(p.SampleRateHertz) / float64(sampleRateHertz)
+	frames := int(float64(p.Frames()) / ratio)
+
+	out := &PCM{SampleRateHertz: sampleRateHertz, Channels: make([][]int16, len(p.Channels))}
+
+	for ch, samples := range p.Channels {
+
+		if ratio > 1 {
+			samples = smooth(samples, int(math.Ceil(ratio)))
+		}
+
+		resampled := make([]int16, frames)
+		for i := range resampled {
+
+			pos := float64(i) * ratio
+			left := int(pos)
+			right := min(left+1, len(samples)-1)
+			frac := pos - float64(left)
+
+			resampled[i] = int16(math.Round(float64(samples[left])*(1-frac) + float64(samples[right])*frac))
+		}
+
+		out.Channels[ch] = resampled
+	}
+
+	return out
+}
+
+// Encode возвращает отсчёты всех каналов вперемешку (interleaved) с нужной разрядностью, little-endian
+func (p *PCM) Encode(bitsPerSample int) []byte {
+
+	bytesPerSample := bitsPerSample / 8
+	data := make([]byte, 0, p.Frames()*len(p.Channels)*bytesPerSample)
+
+	for i := 0; i < p.Frames(); i++ {
+		for _, ch := range p.Channels {
+			if bytesPerSample == 1 {
+				data = append(data, byte(ch[i]>>8)+128)
+			} else {
+				data = binary.LittleEndian.AppendUint16(data, uint16(ch[i]))
+			}
+		}
+	}
+
+	return data
+}
+
+// Convert приводит wav-файл к формату, который требует сервис ASR
+func Convert(data []byte, req asr.Requirements) (asr.Audio, error) {
+
+	pcm, err := Decode(data)
+	if err != nil {
+		return asr.Audio{}, err
+	}
+
+	return convert(pcm, req)
+}
+
+// Split приводит wav-файл к формату сервиса ASR, который принимает только моно, отдельно для каждого канала.
+// Каналы записи разговора не сводятся: в каждом из них свой собеседник.
+// Если сервис принимает многоканальное аудио или запись моно, возвращается одна запись.
+func Split(data []byte, req asr.Requirements) ([]asr.Audio, error) {
+
+	pcm, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Channels != 1 || len(pcm.Channels) == 1 {
+		audio, err := convert(pcm, req)
+		if err != nil {
+			return nil, err
+		}
+		return []asr.Audio{audio}, nil
+	}
+
+	audios := make([]asr.Audio, 0, len(pcm.Channels))
+	for ch := range pcm.Channels {
+		audio, err := convert(pcm.Channel(ch), req)
+		if err != nil {
+			return nil, err
+		}
+		audios = append(audios, audio)
+	}
+
+	return audios, nil
+}
+
+func convert(pcm *PCM, req asr.Requirements) (asr.Audio, error) {
+
+	switch {
+	case req.Channels == 0 || req.Channels == len(pcm.Channels):
+	case req.Channels == 1:
+		pcm = pcm.Downmix()
+	default:
+		return asr.Audio{}, fmt.Errorf("cannot convert %d channels to %d", len(pcm.Channels), req.Channels)
+	}
+
+	pcm = pcm.Resample(req.SampleRateHertz)
+
+	return pcm.Audio(req)
+}
+
+// Audio кодирует PCM в формат и разрядность из требований сервиса ASR
+func (p *PCM) Audio(req asr.Requirements) (asr.Audio, error) {
+
+	bitsPerSample := req.BitsPerSample
+	if bitsPerSample == 0 {
+		bitsPerSample = 16
+	}
+
+	if bitsPerSample != 8 && bitsPerSample != 16 {
+		return asr.Audio{}, fmt.Errorf("unsupported bit depth %d", bitsPerSample)
+	}
+
+	audio := asr.Audio{
+		Data:            p.Encode(bitsPerSample),
+		Format:          asr.FormatLPCM,
+		SampleRateHertz: p.SampleRateHertz,
+		BitsPerSample:   bitsPerSample,
+		Channels:        len(p.Channels),
+	}
+
+	switch req.Format {
+	case asr.FormatLPCM:
+	case asr.FormatWAV, "":
+		audio.Data = wav.Encode(wav.Info{SampleRateHertz: audio.SampleRateHertz, BitsPerSample: bitsPerSample, Channels: audio.Channels}, audio.Data)
+		audio.Format = asr.FormatWAV
+	default:
+		return asr.Audio{}, fmt.Errorf("unsupported audio format %s", req.Format)
+	}
+
+	return audio, nil
+}
+
+func smooth(samples []int16, window int) []int16 {
+
+	out := make([]int16, len(samples))
+
+	var sum int
+	for i, s := range samples {
+		sum += int(s)
+		if i >= window {
+			sum -= int(samples[i-window])
+		}
+		out[i] = int16(sum / min(i+1, window))
+	}
+
+	return out
+}
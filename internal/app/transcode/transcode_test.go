@@ -0,0 +1,176 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/RecoBattle/internal/app/asr"
+	"github.com/RecoBattle/internal/app/wav"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stereo16(left, right []int16, sampleRateHertz int) []byte {
+
+	data := make([]byte, 0, len(left)*4)
+	for i := range left {
+		data = binary.LittleEndian.AppendUint16(data, uint16(left[i]))
+		data = binary.LittleEndian.AppendUint16(data, uint16(right[i]))
+	}
+
+	return wav.Encode(wav.Info{SampleRateHertz: sampleRateHertz, BitsPerSample: 16, Channels: 2}, data)
+}
+
+func TestDecode(t *testing.T) {
+
+	t.Run("16 bit stereo", func(t *testing.T) {
+
+		pcm, err := Decode(stereo16([]int16{1, -2, 3}, []int16{-100, 200, -300}, 8000))
+		require.NoError(t, err)
+
+		assert.Equal(t, 8000, pcm.SampleRateHertz)
+		assert.Equal(t, [][]int16{{1, -2, 3}, {-100, 200, -300}}, pcm.Channels)
+	})
+
+	t.Run("8 bit mono", func(t *testing.T) {
+
+		data := wav.Encode(wav.Info{SampleRateHertz: 8000, BitsPerSample: 8, Channels: 1}, []byte{0, 128, 255})
+
+		pcm, err := Decode(data)
+		require.NoError(t, err)
+
+		assert.Equal(t, [][]int16{{-32768, 0, 32512}}, pcm.Channels)
+		assert.Equal(t, []byte{0, 128, 255}, pcm.Encode(8))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+
+		_, err := Decode([]byte("RIFF"))
+		assert.ErrorIs(t, err, wav.ErrInvalid)
+	})
+}
+
+func TestPCM_Downmix(t *testing.T) {
+
+	pcm := &PCM{SampleRateHertz: 8000, Channels: [][]int16{{100, -100, 32767}, {300, 100, 32767}}}
+
+	assert.Equal(t, [][]int16{{200, 0, 32767}}, pcm.Downmix().Channels)
+	assert.Equal(t, [][]int16{{300, 100, 32767}}, pcm.Channel(1).Channels)
+}
+
+func TestPCM_Resample(t *testing.T) {
+
+	const frequency = 440.0
+
+	sine := func(sampleRateHertz, frames int) []int16 {
+		samples := make([]int16, frames)
+		for i := range samples {
+			samples[i] = int16(10000 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRateHertz)))
+		}
+		return samples
+	}
+
+	for _, tt := range []struct {
+		name string
+		from int
+		to   int
+	}{
+		{name: "Down", from: 16000, to: 8000},
+		{name: "Up", from: 8000, to: 16000},
+		{name: "Fractional", from: 44100, to: 16000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+
+			pcm := &PCM{SampleRateHertz: tt.from, Channels: [][]int16{sine(tt.from, tt.from)}}
+
+			resampled := pcm.Resample(tt.to)
+
+			assert.Equal(t, tt.to, resampled.SampleRateHertz)
+			assert.Equal(t, tt.to, resampled.Frames())
+
+			// сигнал после пересэмплирования близок к тому же синусу, с учётом задержки сглаживающего фильтра
+			delay := 0.0
+			if tt.from > tt.to {
+				delay = (math.Ceil(float64(tt.from)/float64(tt.to)) - 1) / 2 / float64(tt.from) * float64(tt.to)
+			}
+
+			var maxDiff float64
+			for i := 10; i < tt.to-10; i++ {
+				expected := 10000 * math.Sin(2*math.Pi*frequency*(float64(i)-delay)/float64(tt.to))
+				maxDiff = math.Max(maxDiff, math.Abs(float64(resampled.Channels[0][i])-expected))
+			}
+			assert.Less(t, maxDiff, 500.0)
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+
+	data := stereo16(make([]int16, 1600), make([]int16, 1600), 16000)
+
+	t.Run("LPCM mono", func(t *testing.T) {
+
+		audio, err := Convert(data, asr.Requirements{Format: asr.FormatLPCM, SampleRateHertz: 8000, BitsPerSample: 16, Channels: 1})
+		require.NoError(t, err)
+
+		assert.Equal(t, asr.FormatLPCM, audio.Format)
+		assert.Equal(t, 1, audio.Channels)
+		assert.Equal(t, 8000, audio.SampleRateHertz)
+		assert.Len(t, audio.Data, 800*2)
+		assert.InDelta(t, 0.1, audio.Duration(), 0.0001)
+	})
+
+	t.Run("WAV as is", func(t *testing.T) {
+
+		audio, err := Convert(data, asr.Requirements{})
+		require.NoError(t, err)
+
+		assert.Equal(t, asr.FormatWAV, audio.Format)
+		assert.Equal(t, data, audio.Data)
+	})
+
+	t.Run("8 bit", func(t *testing.T) {
+
+		audio, err := Convert(data, asr.Requirements{Format: asr.FormatWAV, BitsPerSample: 8, Channels: 1})
+		require.NoError(t, err)
+
+		info, err := wav.Decode(audio.Data)
+		require.NoError(t, err)
+		assert.Equal(t, 8, info.BitsPerSample)
+		assert.Equal(t, 1600, info.DataSize)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+
+		_, err := Convert(data, asr.Requirements{Channels: 6})
+		assert.Error(t, err)
+
+		_, err = Convert(data, asr.Requirements{BitsPerSample: 24})
+		assert.Error(t, err)
+	})
+}
+
+func TestSplit(t *testing.T) {
+
+	data := stereo16([]int16{1, 2, 3, 4}, []int16{-1, -2, -3, -4}, 8000)
+
+	mono := asr.Requirements{Format: asr.FormatLPCM, BitsPerSample: 16, Channels: 1}
+
+	audios, err := Split(data, mono)
+	require.NoError(t, err)
+	require.Len(t, audios, 2)
+
+	assert.Equal(t, []byte{1, 0, 2, 0, 3, 0, 4, 0}, audios[0].Data)
+	assert.Equal(t, []byte{0xff, 0xff, 0xfe, 0xff, 0xfd, 0xff, 0xfc, 0xff}, audios[1].Data)
+	assert.Equal(t, 1, audios[1].Channels)
+
+	audios, err = Split(data, asr.Requirements{Format: asr.FormatLPCM})
+	require.NoError(t, err)
+	require.Len(t, audios, 1)
+	assert.Equal(t, 2, audios[0].Channels)
+
+	audios, err = Split(wav.Encode(wav.Info{SampleRateHertz: 8000, BitsPerSample: 16, Channels: 1}, []byte{1, 0}), mono)
+	require.NoError(t, err)
+	assert.Len(t, audios, 1)
+}
@@ -0,0 +1,136 @@
+package userapp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RecoBattle/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// ScopeRead чтение: списки файлов, результаты, оценки качества, скачивание аудио
+	ScopeRead = "read"
+	// ScopeUpload загрузка файлов и постановка заданий на распознавание
+	ScopeUpload = "upload"
+	// ScopeQualityControl загрузка эталонных текстов
+	ScopeQualityControl = "qualitycontrol"
+
+	apiKeyPrefix    = "rb_"
+	apiKeySecretLen = 32
+	apiKeyShownLen  = len(apiKeyPrefix) + 8
+)
+
+// Scopes все scope, которые можно выдать API-ключу
+var Scopes = []string{ScopeRead, ScopeUpload, ScopeQualityControl}
+
+// APIKey долгоживущий ключ пользователя для скриптов и CI. В БД хранится только SHA-256 ключа,
+// сам ключ показывается один раз при создании. Пустой список Scopes — полный доступ
+type APIKey struct {
+	UUID       uuid.UUID  `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Allows сообщает, разрешён ли ключу запрос, которому нужен scope. Пустой scope означает, что запрос доступен только по JWT
+func (k APIKey) Allows(scope string) bool {
+
+	if scope == "" {
+		return false
+	}
+
+	return len(k.Scopes) == 0 || slices.Contains(k.Scopes, scope)
+}
+
+func (ua *Users) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (*CreatedAPIKey, error) {
+
+	secret := make([]byte, apiKeySecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := APIKey{
+		UUID:      uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:apiKeyShownLen],
+		Hash:      hashAPIKey(key),
+		Scopes:    compactScopes(scopes),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := ua.userStore.CreateAPIKey(ctx, apiKey); err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+func (ua *Users) GetAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	return ua.userStore.GetAPIKeys(ctx, userID)
+}
+
+func (ua *Users) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	return ua.userStore.RevokeAPIKey(ctx, userID, keyID)
+}
+
+// AuthenticateAPIKey находит действующий ключ по его хешу и отмечает время использования
+func (ua *Users) AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error) {
+
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrUnauthorized
+	}
+
+	apiKey, err := ua.userStore.UseAPIKey(ctx, hashAPIKey(key))
+	if err != nil {
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return nil, ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+// ключ содержит 256 бит случайных данных, поэтому медленный KDF для него не нужен
+func hashAPIKey(key string) string {
+
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func compactScopes(scopes []string) []string {
+
+	result := make([]string, 0, len(scopes))
+
+	for _, scope := range scopes {
+		if !slices.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+
+	slices.Sort(result)
+
+	return result
+}
@@ -6,13 +6,22 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/database"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/labstack/gommon/log"
 )
 
+// ErrUnauthorized неверный логин/пароль или недействительный refresh-токен
+var ErrUnauthorized = errors.New("401")
+
+// ErrDisabled учётная запись отключена администратором
+var ErrDisabled = errors.New("403")
+
 type JWTCustomClaims struct {
-	UserID string `json:"user_id"`
+	UserID   string `json:"user_id"`
+	FamilyID string `json:"fid,omitempty"`
+	Role     string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -21,9 +30,9 @@ type LoginResponse struct {
 	RefreshToken string
 }
 
-func (ua *Users) newToken(user User, tokenExpiresAt uint, SecretKeyForToken string) (string, error) {
+func (ua *Users) newToken(user User, familyID, jti string, tokenExpiresAt uint, SecretKeyForToken string) (string, error) {
 
-	token := ua.getTokensWithClaims(user, tokenExpiresAt)
+	token := ua.getTokensWithClaims(user, familyID, jti, tokenExpiresAt)
 
 	tokenString, err := token.SignedString([]byte(SecretKeyForToken))
 	if err != nil {
@@ -34,11 +43,14 @@ func (ua *Users) newToken(user User, tokenExpiresAt uint, SecretKeyForToken stri
 	return tokenString, nil
 }
 
-func (ua *Users) getTokensWithClaims(user User, tokenExpiresAt uint) (token *jwt.Token) {
+func (ua *Users) getTokensWithClaims(user User, familyID, jti string, tokenExpiresAt uint) (token *jwt.Token) {
 
 	tokenClaims := &JWTCustomClaims{
-		UserID: user.UUID.String(),
+		UserID:   user.UUID.String(),
+		FamilyID: familyID,
+		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(tokenExpiresAt) * time.Minute)),
 		},
 	}
@@ -48,28 +60,85 @@ func (ua *Users) getTokensWithClaims(user User, tokenExpiresAt uint) (token *jwt
 	return token
 }
 
-func (ua *Users) Token(ctx context.Context, refreshToken string, cfg config.ApiServer) (*LoginResponse, error) {
+// issueTokens выдаёт пару токенов одного семейства. Refresh-токен сохраняется в БД по jti,
+// чтобы его можно было использовать только один раз и отозвать
+func (ua *Users) issueTokens(ctx context.Context, user User, familyID string) (*LoginResponse, error) {
 
-	valid, userClaims, err := ParseToken(refreshToken, cfg.SecretKeyForRefreshToken)
+	jti := uuid.NewString()
+
+	accessToken, err := ua.newToken(user, familyID, "", ua.Cfg.AccessTokenExpiresAt, ua.Cfg.SecretKeyForAccessToken)
 	if err != nil {
 		return nil, err
 	}
 
-	if valid {
-		user, err := ua.userStore.GetUser(ctx, map[string]string{"uuid": userClaims.UserID})
-		if err != nil {
-			return nil, err
+	refreshToken, err := ua.newToken(user, familyID, jti, ua.Cfg.RefreshTokenExpiresAt, ua.Cfg.SecretKeyForRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ua.userStore.CreateRefreshToken(ctx, RefreshToken{
+		JTI:       jti,
+		FamilyID:  familyID,
+		UserID:    user.UUID.String(),
+		ExpiresAt: time.Now().Add(time.Duration(ua.Cfg.RefreshTokenExpiresAt) * time.Minute),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов, старый refresh-токен при этом становится использованным.
+// Повторное предъявление уже использованного токена означает, что он утёк, поэтому отзывается всё семейство токенов.
+// Исключение — токен, обменянный не раньше RefreshTokenReuseGrace секунд назад: параллельные запросы браузера
+// предъявляют одну и ту же cookie, и каждый из них получает свою пару того же семейства
+func (ua *Users) Refresh(ctx context.Context, refreshToken string) (*LoginResponse, error) {
+
+	valid, userClaims, err := ParseToken(refreshToken, ua.Cfg.SecretKeyForRefreshToken)
+	if err != nil || !valid || userClaims.ID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	token, err := ua.userStore.UseRefreshToken(ctx, userClaims.ID)
+	if err != nil {
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return nil, ErrUnauthorized
 		}
+		return nil, err
+	}
+
+	if token.Revoked || token.UserID != userClaims.UserID {
+		return nil, ErrUnauthorized
+	}
+
+	if token.Used && time.Since(token.UsedAt) >= time.Duration(ua.Cfg.RefreshTokenReuseGrace)*time.Second {
+		log.Warnf("refresh token %s reused, revoking token family %s", token.JTI, token.FamilyID)
 
-		accessToken, err := ua.newToken(*user, cfg.AccessTokenExpiresAt, cfg.SecretKeyForAccessToken)
-		if err != nil {
+		if err = ua.userStore.RevokeRefreshTokens(ctx, token.UserID, token.FamilyID); err != nil {
 			return nil, err
 		}
 
-		return &LoginResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+		return nil, ErrUnauthorized
 	}
 
-	return nil, errors.New("401")
+	user, err := ua.userStore.GetUser(ctx, map[string]string{"uuid": token.UserID})
+	if err != nil {
+		return nil, err
+	}
+
+	// роль перечитывается из БД, поэтому её изменение вступает в силу при следующем обмене токенов
+	if user.Disabled {
+		return nil, ErrUnauthorized
+	}
+
+	return ua.issueTokens(ctx, *user, token.FamilyID)
+}
+
+// Logout отзывает refresh-токены сессии. Если семейство неизвестно (токен выдан до появления семейств), отзываются все токены пользователя
+func (ua *Users) Logout(ctx context.Context, userID, familyID string) error {
+	return ua.userStore.RevokeRefreshTokens(ctx, userID, familyID)
 }
 
 func ParseToken(tokenstr, secretKey string) (bool, *JWTCustomClaims, error) {
@@ -0,0 +1,130 @@
+package userapp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Параметры argon2id по RFC 9106 (вариант для ограниченной памяти)
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+
+	argon2Prefix = "$argon2id$"
+)
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+var currentArgon2Params = argon2Params{time: argon2Time, memory: argon2Memory, threads: argon2Threads}
+
+// hashPassword возвращает хеш пароля в формате PHC: $argon2id$v=19$m=65536,t=3,p=4$<соль>$<хеш>.
+// Соль и параметры хранятся в самой строке, поэтому секрет сервиса для проверки не нужен
+func hashPassword(password string) (string, error) {
+
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return encodeArgon2(currentArgon2Params, salt, password), nil
+}
+
+func encodeArgon2(p argon2Params, salt []byte, password string) string {
+
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// verifyPassword проверяет пароль по хешу из БД. needsRehash сообщает, что хеш нужно пересчитать в текущем формате:
+// это старый HMAC-SHA256 или argon2id с устаревшими параметрами
+func (ua *Users) verifyPassword(user User, stored string) (ok, needsRehash bool) {
+
+	if !strings.HasPrefix(stored, argon2Prefix) {
+		return ua.checkHash(user, stored), true
+	}
+
+	p, salt, key, err := decodeArgon2(stored)
+	if err != nil {
+		return false, false
+	}
+
+	check := argon2.IDKey([]byte(user.Password), salt, p.time, p.memory, p.threads, uint32(len(key)))
+
+	if subtle.ConstantTimeCompare(check, key) != 1 {
+		return false, false
+	}
+
+	return true, p != currentArgon2Params
+}
+
+func decodeArgon2(stored string) (argon2Params, []byte, []byte, error) {
+
+	var p argon2Params
+	var version int
+
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, fmt.Errorf("invalid argon2id hash format")
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2id parameters %q: %w", parts[3], err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, err
+	}
+
+	if len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("empty argon2id hash")
+	}
+
+	return p, salt, key, nil
+}
+
+// checkHash проверяет хеш в старом формате hex(HMAC-SHA256(secret, login:password:secret))
+func (ua *Users) checkHash(user User, userHash string) bool {
+
+	check1 := ua.writeHash(user.Username, user.Password)
+	check2, err := hex.DecodeString(userHash)
+
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(check2, check1)
+}
+
+func (ua *Users) writeHash(username string, password string) []byte {
+	hash := hmac.New(sha256.New, []byte(ua.Cfg.SecretKeyForHashingPassword))
+	hash.Write([]byte(fmt.Sprintf("%s:%s:%s", username, password, ua.Cfg.SecretKeyForHashingPassword)))
+
+	return hash.Sum(nil)
+}
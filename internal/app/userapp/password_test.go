@@ -0,0 +1,64 @@
+package userapp
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHash(t *testing.T) {
+
+	ua := NewUser(nil, config.ApiServer{SecretKeyForHashingPassword: "secret"})
+	user := User{Username: "testuser", Password: "testpassword"}
+
+	t.Run("argon2id", func(t *testing.T) {
+
+		hash, err := hashPassword(user.Password)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$"), hash)
+
+		other, err := hashPassword(user.Password)
+		require.NoError(t, err)
+		assert.NotEqual(t, hash, other, "salt must be random")
+
+		ok, needsRehash := ua.verifyPassword(user, hash)
+		assert.True(t, ok)
+		assert.False(t, needsRehash)
+
+		ok, _ = ua.verifyPassword(User{Username: "testuser", Password: "wrong"}, hash)
+		assert.False(t, ok)
+	})
+
+	t.Run("Outdated argon2id parameters", func(t *testing.T) {
+
+		hash := encodeArgon2(argon2Params{time: 1, memory: 8 * 1024, threads: 1}, []byte("somesaltsomesalt"), user.Password)
+
+		ok, needsRehash := ua.verifyPassword(user, hash)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+	})
+
+	t.Run("Legacy HMAC", func(t *testing.T) {
+
+		legacy := hex.EncodeToString(ua.writeHash(user.Username, user.Password))
+
+		ok, needsRehash := ua.verifyPassword(user, legacy)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+
+		ok, _ = ua.verifyPassword(User{Username: "testuser", Password: "wrong"}, legacy)
+		assert.False(t, ok)
+	})
+
+	t.Run("Malformed hash", func(t *testing.T) {
+
+		for _, hash := range []string{"", "$argon2id$", "$argon2id$v=19$m=65536,t=3,p=4$!!$!!", "$argon2id$v=18$m=65536,t=3,p=4$c2FsdA$a2V5"} {
+			ok, _ := ua.verifyPassword(user, hash)
+			assert.False(t, ok, hash)
+		}
+	})
+}
@@ -2,26 +2,48 @@ package userapp
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
-	"errors"
-	"fmt"
 	"log"
+	"time"
 
 	"github.com/RecoBattle/cmd/config"
 	"github.com/google/uuid"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	UUID     uuid.UUID
 	Username string
 	Password string
+	Role     string
+	Disabled bool
+}
+
+// RefreshToken запись о выданном refresh-токене. Used и Revoked отражают состояние до вызова UseRefreshToken
+type RefreshToken struct {
+	JTI       string
+	FamilyID  string
+	UserID    string
+	ExpiresAt time.Time
+	Used      bool
+	UsedAt    time.Time
+	Revoked   bool
 }
 
 type UserStore interface {
 	Create(ctx context.Context, user User) error
 	GetUser(ctx context.Context, condition map[string]string) (*User, error)
+	UpdatePassword(ctx context.Context, userID, hash string) error
+	CreateRefreshToken(ctx context.Context, token RefreshToken) error
+	UseRefreshToken(ctx context.Context, jti string) (*RefreshToken, error)
+	RevokeRefreshTokens(ctx context.Context, userID, familyID string) error
+	CreateAPIKey(ctx context.Context, apiKey APIKey) error
+	GetAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID string) error
+	UseAPIKey(ctx context.Context, hash string) (*APIKey, error)
 }
 
 type Users struct {
@@ -39,23 +61,20 @@ func NewUser(userStore UserStore, cfg config.ApiServer) *Users {
 func (ua *Users) Register(ctx context.Context, user User) (*LoginResponse, error) {
 
 	user.UUID = uuid.New()
-	user.Password = hex.EncodeToString(ua.writeHash(user.Username, user.Password))
+	user.Role = RoleUser
 
-	if err := ua.userStore.Create(ctx, user); err != nil {
-		return nil, err
-	}
-
-	accessToken, err := ua.newToken(user, ua.Cfg.AccessTokenExpiresAt, ua.Cfg.SecretKeyForAccessToken)
+	hash, err := hashPassword(user.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := ua.newToken(user, ua.Cfg.RefreshTokenExpiresAt, ua.Cfg.SecretKeyForRefreshToken)
-	if err != nil {
+	user.Password = hash
+
+	if err := ua.userStore.Create(ctx, user); err != nil {
 		return nil, err
 	}
 
-	return &LoginResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+	return ua.issueTokens(ctx, user, uuid.NewString())
 }
 
 func (ua *Users) Login(ctx context.Context, user User) (*LoginResponse, error) {
@@ -66,39 +85,23 @@ func (ua *Users) Login(ctx context.Context, user User) (*LoginResponse, error) {
 		return nil, err
 	}
 
-	if !ua.checkHash(user, userInDB.Password) {
-		return nil, errors.New("401")
-	}
-
-	accessToken, err := ua.newToken(*userInDB, ua.Cfg.AccessTokenExpiresAt, ua.Cfg.SecretKeyForAccessToken)
-	if err != nil {
-		return nil, err
+	ok, needsRehash := ua.verifyPassword(user, userInDB.Password)
+	if !ok {
+		return nil, ErrUnauthorized
 	}
 
-	refreshToken, err := ua.newToken(*userInDB, ua.Cfg.RefreshTokenExpiresAt, ua.Cfg.SecretKeyForRefreshToken)
-	if err != nil {
-		return nil, err
+	if userInDB.Disabled {
+		return nil, ErrDisabled
 	}
 
-	return &LoginResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
-}
-
-func (ua *Users) checkHash(user User, userHash string) bool {
-
-	check1 := ua.writeHash(user.Username, user.Password)
-	check2, err := hex.DecodeString(userHash)
-
-	if err != nil {
-		log.Printf("Error in decode user hash. error: %v\n", err)
+	// старый хеш заменяется на argon2id, пока известен пароль; ошибка не мешает входу
+	if needsRehash {
+		if hash, err := hashPassword(user.Password); err != nil {
+			log.Printf("Error in rehash user password. error: %v\n", err)
+		} else if err = ua.userStore.UpdatePassword(ctx, userInDB.UUID.String(), hash); err != nil {
+			log.Printf("Error in update user password hash. error: %v\n", err)
+		}
 	}
 
-	return hmac.Equal(check2, check1)
-
-}
-
-func (ua *Users) writeHash(username string, password string) []byte {
-	hash := hmac.New(sha256.New, []byte(ua.Cfg.SecretKeyForHashingPassword))
-	hash.Write([]byte(fmt.Sprintf("%s:%s:%s", username, password, ua.Cfg.SecretKeyForHashingPassword)))
-
-	return hash.Sum(nil)
+	return ua.issueTokens(ctx, *userInDB, uuid.NewString())
 }
@@ -0,0 +1,259 @@
+package wav
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	CodecPCM       = "pcm"
+	CodecFloat     = "ieee_float"
+	CodecALaw      = "alaw"
+	CodecMuLaw     = "mulaw"
+	CodecUndefined = "undefined"
+
+	formatPCM        = 0x0001
+	formatFloat      = 0x0003
+	formatALaw       = 0x0006
+	formatMuLaw      = 0x0007
+	formatExtensible = 0xFFFE
+
+	maxFmtSize = 40
+
+	MinSampleRateHertz = 8000
+	MaxSampleRateHertz = 48000
+	MaxChannels        = 2
+	MaxDuration        = 4 * 60 * 60
+)
+
+// ErrInvalid возвращается для данных, которые не являются поддерживаемым wav-файлом
+var ErrInvalid = errors.New("invalid wav file")
+
+// Info описывает формат wav-файла и положение PCM-данных в нём
+type Info struct {
+	Codec           string  `json:"codec"`
+	SampleRateHertz int     `json:"sample_rate_hertz"`
+	BitsPerSample   int     `json:"bits_per_sample"`
+	Channels        int     `json:"channels"`
+	Duration        float64 `json:"duration"`
+	DataOffset      int     `json:"-"`
+	DataSize        int     `json:"-"`
+}
+
+// BlockAlign размер одного фрейма (отсчёт всех каналов) в байтах
+func (i Info) BlockAlign() int {
+	return i.Channels * i.BitsPerSample / 8
+}
+
+// Parse разбирает RIFF/WAVE заголовок и находит блок данных
+func Parse(data []byte) (*Info, error) {
+	return ParseReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// ParseReader разбирает заголовок wav-файла размером size, не читая его целиком в память
+func ParseReader(r io.ReaderAt, size int64) (*Info, error) {
+
+	header := make([]byte, 12)
+	if size < 12 || !readAt(r, header, 0) || !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		return nil, fmt.Errorf("%w: no RIFF/WAVE header", ErrInvalid)
+	}
+
+	var info *Info
+
+	chunk := make([]byte, 8)
+
+	for offset := int64(12); offset+8 <= size; {
+
+		if !readAt(r, chunk, offset) {
+			return nil, fmt.Errorf("%w: truncated chunk header", ErrInvalid)
+		}
+
+		id := string(chunk[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		body := offset + 8
+
+		switch id {
+		case "fmt ":
+			if chunkSize < 16 || body+chunkSize > size {
+				return nil, fmt.Errorf("%w: truncated fmt chunk", ErrInvalid)
+			}
+
+			// после поля SubFormat в блоке fmt нет ничего нужного
+			fmtChunk := make([]byte, min(chunkSize, maxFmtSize))
+			if !readAt(r, fmtChunk, body) {
+				return nil, fmt.Errorf("%w: truncated fmt chunk", ErrInvalid)
+			}
+			info = parseFmt(fmtChunk)
+
+		case "data":
+			if info == nil {
+				return nil, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalid)
+			}
+
+			// часть записывающих программ не обновляет размер блока данных или обрезает файл,
+			// поэтому берём столько целых фреймов, сколько реально есть в файле
+			chunkSize = min(chunkSize, size-body)
+			if blockAlign := int64(info.BlockAlign()); blockAlign > 0 {
+				chunkSize -= chunkSize % blockAlign
+			}
+
+			info.DataOffset = int(body)
+			info.DataSize = int(chunkSize)
+
+			if byteRate := info.SampleRateHertz * info.BlockAlign(); byteRate > 0 {
+				info.Duration = float64(chunkSize) / float64(byteRate)
+			}
+
+			return info, nil
+		}
+
+		// блоки выравниваются по чётной границе
+		offset = body + chunkSize + chunkSize%2
+	}
+
+	if info == nil {
+		return nil, fmt.Errorf("%w: no fmt chunk", ErrInvalid)
+	}
+
+	return nil, fmt.Errorf("%w: no data chunk", ErrInvalid)
+}
+
+// Validate проверяет, что формат поддерживается сервисом
+func (i Info) Validate() error {
+
+	switch {
+	case i.Codec != CodecPCM:
+		return fmt.Errorf("%w: unsupported codec %s", ErrInvalid, i.Codec)
+	case i.BitsPerSample != 8 && i.BitsPerSample != 16:
+		return fmt.Errorf("%w: unsupported bit depth %d", ErrInvalid, i.BitsPerSample)
+	case i.SampleRateHertz < MinSampleRateHertz || i.SampleRateHertz > MaxSampleRateHertz:
+		return fmt.Errorf("%w: unsupported sample rate %d", ErrInvalid, i.SampleRateHertz)
+	case i.Channels < 1 || i.Channels > MaxChannels:
+		return fmt.Errorf("%w: unsupported channel count %d", ErrInvalid, i.Channels)
+	case i.DataSize == 0:
+		return fmt.Errorf("%w: no audio data", ErrInvalid)
+	case i.Duration > MaxDuration:
+		return fmt.Errorf("%w: duration %.0fs exceeds %ds", ErrInvalid, i.Duration, MaxDuration)
+	}
+
+	return nil
+}
+
+// Decode разбирает и проверяет wav-файл
+func Decode(data []byte) (*Info, error) {
+
+	info, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = info.Validate(); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// DecodeReader разбирает и проверяет wav-файл размером size, не читая его целиком в память
+func DecodeReader(r io.ReaderAt, size int64) (*Info, error) {
+
+	info, err := ParseReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = info.Validate(); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// PCM возвращает PCM-данные без заголовка
+func (i Info) PCM(data []byte) []byte {
+	return data[i.DataOffset : i.DataOffset+i.DataSize]
+}
+
+// ContentHash SHA-256 декодированного аудио: параметров формата и PCM-данных без заголовка. Служебные блоки файла,
+// например LIST/INFO, в хеш не входят, поэтому одна и та же запись с разными метаданными даёт один хеш
+func (i Info) ContentHash(r io.ReaderAt) (string, error) {
+
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "%s:%d:%d:%d:", i.Codec, i.SampleRateHertz, i.BitsPerSample, i.Channels)
+
+	if _, err := io.Copy(hash, io.NewSectionReader(r, int64(i.DataOffset), int64(i.DataSize))); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Encode собирает wav-файл из PCM-данных в формате info
+func Encode(info Info, pcm []byte) []byte {
+
+	blockAlign := info.BlockAlign()
+
+	b := make([]byte, 0, 44+len(pcm))
+
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+len(pcm)))
+	b = append(b, "WAVE"...)
+
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, formatPCM)
+	b = binary.LittleEndian.AppendUint16(b, uint16(info.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(info.SampleRateHertz))
+	b = binary.LittleEndian.AppendUint32(b, uint32(info.SampleRateHertz*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(info.BitsPerSample))
+
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(pcm)))
+
+	return append(b, pcm...)
+}
+
+func readAt(r io.ReaderAt, b []byte, offset int64) bool {
+	n, err := r.ReadAt(b, offset)
+	return n == len(b) && (err == nil || errors.Is(err, io.EOF))
+}
+
+func parseFmt(chunk []byte) *Info {
+
+	format := binary.LittleEndian.Uint16(chunk[0:2])
+
+	// в WAVE_FORMAT_EXTENSIBLE настоящий кодек лежит в первых двух байтах SubFormat GUID
+	if format == formatExtensible && len(chunk) >= 26 {
+		format = binary.LittleEndian.Uint16(chunk[24:26])
+	}
+
+	return &Info{
+		Codec:           codecName(format),
+		Channels:        int(binary.LittleEndian.Uint16(chunk[2:4])),
+		SampleRateHertz: int(binary.LittleEndian.Uint32(chunk[4:8])),
+		BitsPerSample:   int(binary.LittleEndian.Uint16(chunk[14:16])),
+	}
+}
+
+func codecName(format uint16) string {
+
+	switch format {
+	case formatPCM:
+		return CodecPCM
+	case formatFloat:
+		return CodecFloat
+	case formatALaw:
+		return CodecALaw
+	case formatMuLaw:
+		return CodecMuLaw
+	}
+
+	return CodecUndefined
+}
@@ -0,0 +1,156 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func header(format uint16, channels, sampleRate, bits int, dataSize uint32) []byte {
+
+	blockAlign := channels * bits / 8
+
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, 36+dataSize)
+	b = append(b, "WAVE"...)
+
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, format)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, dataSize)
+
+	return b
+}
+
+func TestDecode(t *testing.T) {
+
+	t.Run("PCM", func(t *testing.T) {
+
+		data := append(header(formatPCM, 2, 8000, 16, 32000), make([]byte, 32000)...)
+
+		info, err := Decode(data)
+		if assert.NoError(t, err) {
+			assert.Equal(t, &Info{Codec: CodecPCM, SampleRateHertz: 8000, BitsPerSample: 16, Channels: 2, Duration: 1, DataOffset: 44, DataSize: 32000}, info)
+			assert.Len(t, info.PCM(data), 32000)
+		}
+	})
+
+	t.Run("Extra chunk before data", func(t *testing.T) {
+
+		data := header(formatPCM, 1, 16000, 16, 0)
+		data = data[:len(data)-8]
+		data = append(data, "LIST"...)
+		data = binary.LittleEndian.AppendUint32(data, 3)
+		data = append(data, 'a', 'b', 'c', 0)
+		data = append(data, "data"...)
+		data = binary.LittleEndian.AppendUint32(data, 16000)
+		data = append(data, make([]byte, 16000)...)
+
+		info, err := Decode(data)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 56, info.DataOffset)
+			assert.Equal(t, 0.5, info.Duration)
+		}
+	})
+
+	t.Run("Truncated data", func(t *testing.T) {
+
+		data := append(header(formatPCM, 1, 8000, 16, 16000), make([]byte, 8001)...)
+
+		info, err := Decode(data)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 8000, info.DataSize)
+		}
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Not a wav", data: []byte("just some text in the audio field")},
+		{name: "No data chunk", data: header(formatPCM, 1, 8000, 16, 0)[:36]},
+		{name: "Empty data", data: header(formatPCM, 1, 8000, 16, 0)},
+		{name: "Codec", data: append(header(formatMuLaw, 1, 8000, 8, 8), make([]byte, 8)...)},
+		{name: "Bit depth", data: append(header(formatPCM, 1, 8000, 24, 6), make([]byte, 6)...)},
+		{name: "Sample rate", data: append(header(formatPCM, 1, 4000, 16, 8), make([]byte, 8)...)},
+		{name: "Channels", data: append(header(formatPCM, 6, 8000, 16, 12), make([]byte, 12)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			assert.True(t, errors.Is(err, ErrInvalid), "got %v", err)
+		})
+	}
+}
+
+func TestDecodeReader(t *testing.T) {
+
+	data := append(header(formatPCM, 1, 16000, 16, 32000), make([]byte, 32000)...)
+
+	info, err := DecodeReader(bytes.NewReader(data), int64(len(data)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &Info{Codec: CodecPCM, SampleRateHertz: 16000, BitsPerSample: 16, Channels: 1, Duration: 1, DataOffset: 44, DataSize: 32000}, info)
+	}
+
+	_, err = DecodeReader(bytes.NewReader(data[:30]), 30)
+	assert.True(t, errors.Is(err, ErrInvalid), "got %v", err)
+}
+
+func TestInfo_ContentHash(t *testing.T) {
+
+	pcm := []byte{1, 0, 2, 0, 3, 0, 4, 0}
+
+	hash := func(data []byte) string {
+		info, err := Decode(data)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+
+		h, err := info.ContentHash(bytes.NewReader(data))
+		assert.NoError(t, err)
+
+		return h
+	}
+
+	plain := Encode(Info{SampleRateHertz: 16000, BitsPerSample: 16, Channels: 1}, pcm)
+
+	// та же запись с блоком метаданных перед данными
+	tagged := header(formatPCM, 1, 16000, 16, 0)
+	tagged = tagged[:len(tagged)-8]
+	tagged = append(tagged, "LIST"...)
+	tagged = binary.LittleEndian.AppendUint32(tagged, 4)
+	tagged = append(tagged, "INFO"...)
+	tagged = append(tagged, "data"...)
+	tagged = binary.LittleEndian.AppendUint32(tagged, uint32(len(pcm)))
+	tagged = append(tagged, pcm...)
+
+	assert.Equal(t, hash(plain), hash(tagged))
+
+	// те же байты в другом формате — другое аудио
+	assert.NotEqual(t, hash(plain), hash(Encode(Info{SampleRateHertz: 8000, BitsPerSample: 16, Channels: 1}, pcm)))
+	assert.NotEqual(t, hash(plain), hash(Encode(Info{SampleRateHertz: 16000, BitsPerSample: 16, Channels: 2}, pcm)))
+}
+
+func TestEncode(t *testing.T) {
+
+	pcm := []byte{1, 0, 2, 0, 3, 0, 4, 0}
+
+	data := Encode(Info{SampleRateHertz: 16000, BitsPerSample: 16, Channels: 2}, pcm)
+	assert.Equal(t, header(formatPCM, 2, 16000, 16, uint32(len(pcm))), data[:44])
+
+	info, err := Decode(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, pcm, info.PCM(data))
+	}
+}
@@ -0,0 +1,202 @@
+package workspaceapp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RoleOwner управляет участниками, может всё, что editor
+	RoleOwner = "owner"
+	// RoleEditor загружает файлы, эталонные тексты и ставит задания
+	RoleEditor = "editor"
+	// RoleViewer только читает файлы, результаты и оценки качества
+	RoleViewer = "viewer"
+)
+
+// Roles роли участника рабочего пространства
+var Roles = []string{RoleOwner, RoleEditor, RoleViewer}
+
+var (
+	// ErrForbidden у участника недостаточно прав для действия
+	ErrForbidden = errors.New("insufficient workspace role")
+	// ErrInvalidRole неизвестная роль участника
+	ErrInvalidRole = errors.New("invalid workspace role")
+	// ErrLastOwner в пространстве должен остаться хотя бы один владелец
+	ErrLastOwner = errors.New("workspace must keep at least one owner")
+)
+
+// Scope пространство, в котором выполняется запрос: личные файлы пользователя или файлы рабочего пространства.
+// Для рабочего пространства Role — роль пользователя в нём, членство проверено до создания Scope
+type Scope struct {
+	UserID      string
+	WorkspaceID string
+	Role        string
+}
+
+// Personal личное пространство пользователя
+func Personal(userID string) Scope {
+	return Scope{UserID: userID}
+}
+
+// Owner владелец файлов в пространстве: рабочее пространство или сам пользователь
+func (s Scope) Owner() string {
+
+	if s.WorkspaceID != "" {
+		return s.WorkspaceID
+	}
+
+	return s.UserID
+}
+
+// Contains сообщает, относится ли к пространству файл, загруженный пользователем userID в рабочее пространство workspaceID.
+// Пустой workspaceID — личный файл, он виден только в личном пространстве загрузившего
+func (s Scope) Contains(userID, workspaceID string) bool {
+
+	if s.WorkspaceID != "" {
+		return workspaceID == s.WorkspaceID
+	}
+
+	return workspaceID == "" && userID == s.UserID
+}
+
+// CanWrite сообщает, может ли пользователь загружать файлы и менять данные в пространстве
+func (s Scope) CanWrite() bool {
+	return s.WorkspaceID == "" || s.Role == RoleOwner || s.Role == RoleEditor
+}
+
+type Workspace struct {
+	UUID      uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"-"`
+}
+
+type Member struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+	Role   string `json:"role"`
+}
+
+type WorkspaceStore interface {
+	CreateWorkspace(ctx context.Context, workspace Workspace) error
+	GetWorkspaces(ctx context.Context, userID string) ([]Workspace, error)
+	GetMemberRole(ctx context.Context, workspaceID, userID string) (string, error)
+	GetMembers(ctx context.Context, workspaceID string) ([]Member, error)
+	SetMember(ctx context.Context, workspaceID, login, role string) (*Member, error)
+	RemoveMember(ctx context.Context, workspaceID, userID string) error
+}
+
+type Workspaces struct {
+	workspaceStore WorkspaceStore
+}
+
+func NewWorkspace(workspaceStore WorkspaceStore) *Workspaces {
+	return &Workspaces{workspaceStore: workspaceStore}
+}
+
+// Create создаёт рабочее пространство, создатель становится его владельцем
+func (wa *Workspaces) Create(ctx context.Context, userID, name string) (*Workspace, error) {
+
+	workspace := Workspace{
+		UUID:      uuid.New(),
+		Name:      name,
+		Role:      RoleOwner,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: userID,
+	}
+
+	if err := wa.workspaceStore.CreateWorkspace(ctx, workspace); err != nil {
+		return nil, err
+	}
+
+	return &workspace, nil
+}
+
+func (wa *Workspaces) GetWorkspaces(ctx context.Context, userID string) ([]Workspace, error) {
+	return wa.workspaceStore.GetWorkspaces(ctx, userID)
+}
+
+// Authorize возвращает Scope рабочего пространства для его участника. Для остальных пространство не существует — NotFoundError
+func (wa *Workspaces) Authorize(ctx context.Context, userID, workspaceID string) (Scope, error) {
+
+	role, err := wa.workspaceStore.GetMemberRole(ctx, workspaceID, userID)
+	if err != nil {
+		return Scope{}, err
+	}
+
+	return Scope{UserID: userID, WorkspaceID: workspaceID, Role: role}, nil
+}
+
+func (wa *Workspaces) GetMembers(ctx context.Context, scope Scope) ([]Member, error) {
+	return wa.workspaceStore.GetMembers(ctx, scope.WorkspaceID)
+}
+
+// SetMember добавляет пользователя по логину или меняет его роль. Доступно только владельцу
+func (wa *Workspaces) SetMember(ctx context.Context, scope Scope, login, role string) (*Member, error) {
+
+	if scope.Role != RoleOwner {
+		return nil, ErrForbidden
+	}
+
+	if !slices.Contains(Roles, role) {
+		return nil, ErrInvalidRole
+	}
+
+	if role != RoleOwner {
+		if err := wa.keepOwner(ctx, scope.WorkspaceID, func(m Member) bool { return m.Login == login }); err != nil {
+			return nil, err
+		}
+	}
+
+	return wa.workspaceStore.SetMember(ctx, scope.WorkspaceID, login, role)
+}
+
+// RemoveMember исключает участника. Владелец исключает любого, остальные могут только выйти сами
+func (wa *Workspaces) RemoveMember(ctx context.Context, scope Scope, userID string) error {
+
+	if scope.Role != RoleOwner && scope.UserID != userID {
+		return ErrForbidden
+	}
+
+	if err := wa.keepOwner(ctx, scope.WorkspaceID, func(m Member) bool { return m.UserID == userID }); err != nil {
+		return err
+	}
+
+	return wa.workspaceStore.RemoveMember(ctx, scope.WorkspaceID, userID)
+}
+
+// keepOwner не даёт снять роль или исключить последнего владельца пространства
+func (wa *Workspaces) keepOwner(ctx context.Context, workspaceID string, changed func(Member) bool) error {
+
+	members, err := wa.workspaceStore.GetMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	owners := 0
+	changedOwner := false
+
+	for _, member := range members {
+		if member.Role != RoleOwner {
+			continue
+		}
+
+		owners++
+
+		if changed(member) {
+			changedOwner = true
+		}
+	}
+
+	if changedOwner && owners == 1 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,348 @@
+package adminhandler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/RecoBattle/internal/app/adminapp"
+	"github.com/RecoBattle/internal/controller/handler"
+	"github.com/RecoBattle/internal/database"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+type AdminHandler struct {
+	AdminApp *adminapp.Admin
+}
+
+type JobsRequest struct {
+	UserID string `query:"user_id"`
+	ASR    string `query:"asr"`
+	Status string `query:"status"`
+	Limit  uint64 `query:"limit" validate:"max=1000"`
+	Offset uint64 `query:"offset"`
+}
+
+type JobStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+}
+
+type RoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+func NewAdminHandler(adminApp *adminapp.Admin) *AdminHandler {
+	return &AdminHandler{AdminApp: adminApp}
+}
+
+func (lh *AdminHandler) RegisterHandler(_ *echo.Echo, _, _ *echo.Group) {}
+
+func (lh *AdminHandler) RegisterAdminHandler(adminGroup *echo.Group) {
+
+	adminGroup.GET("/users", lh.GetUsers)
+	adminGroup.POST("/users/:id/disable", lh.DisableUser)
+	adminGroup.POST("/users/:id/enable", lh.EnableUser)
+	adminGroup.PUT("/users/:id/role", lh.SetUserRole)
+	adminGroup.GET("/jobs", lh.GetJobs)
+	adminGroup.PUT("/jobs/:uuid/status", lh.SetJobStatus)
+
+}
+
+// GetUsers
+//
+//	@Summary      GetUsers
+//	@Description  list all users with their roles and disabled flag
+//	@Success      200 {object} array with users
+//	@Failure      204 {string} no data for an answer
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an administrator
+//	@Failure      500 {string} internal server error
+//	@Router       /api_admin/users [get]
+//
+//	@Security JWT Token
+func (lh *AdminHandler) GetUsers(c echo.Context) error {
+
+	ca := make(chan []adminapp.User)
+	errc := make(chan error)
+
+	go func() {
+
+		users, err := lh.AdminApp.GetUsers(c.Request().Context())
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- users
+	}()
+
+	select {
+	case result := <-ca:
+		if len(result) == 0 {
+			return echo.NewHTTPError(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// DisableUser
+//
+//	@Summary      DisableUser
+//	@Description  disable the account: login, token refresh and API keys stop working
+//	@Success      200 {string} OK
+//	@Failure      400 {string} an administrator cannot disable themselves
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an administrator
+//	@Failure      404 {string} no user with this id
+//	@Failure      500 {string} internal server error
+//	@Router       /api_admin/users/:id/disable [post]
+//
+//	@Security JWT Token
+func (lh *AdminHandler) DisableUser(c echo.Context) error {
+	return lh.setUserDisabled(c, true)
+}
+
+// EnableUser
+//
+//	@Summary      EnableUser
+//	@Description  enable a previously disabled account
+//	@Success      200 {string} OK
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an administrator
+//	@Failure      404 {string} no user with this id
+//	@Failure      500 {string} internal server error
+//	@Router       /api_admin/users/:id/enable [post]
+//
+//	@Security JWT Token
+func (lh *AdminHandler) EnableUser(c echo.Context) error {
+	return lh.setUserDisabled(c, false)
+}
+
+func (lh *AdminHandler) setUserDisabled(c echo.Context, disabled bool) error {
+
+	ca := make(chan bool, 1)
+	errc := make(chan error)
+
+	adminID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	userID := c.Param("id")
+
+	go func() {
+
+		if err := lh.AdminApp.SetUserDisabled(c.Request().Context(), adminID, userID, disabled); err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- true
+	}()
+
+	select {
+	case <-ca:
+		return c.String(http.StatusOK, "OK")
+	case err := <-errc:
+		return adminError(err)
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// SetUserRole
+//
+//	@Summary      SetUserRole
+//	@Description  change the user's role; it takes effect on the next login or token refresh
+//	@Param        json body RoleRequest true "New role: user or admin"
+//	@Success      200 {string} OK
+//	@Failure      400 {string} unknown role or an administrator demoting themselves
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an administrator
+//	@Failure      404 {string} no user with this id
+//	@Failure      500 {string} internal server error
+//	@Router       /api_admin/users/:id/role [put]
+//
+//	@Security JWT Token
+func (lh *AdminHandler) SetUserRole(c echo.Context) error {
+
+	ca := make(chan bool, 1)
+	errc := make(chan error)
+
+	adminID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	request := new(RoleRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind role request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		log.Errorf("error in bind role request. error: %v", err)
+		return err
+	}
+
+	userID := c.Param("id")
+
+	go func() {
+
+		if err := lh.AdminApp.SetUserRole(c.Request().Context(), adminID, userID, request.Role); err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- true
+	}()
+
+	select {
+	case <-ca:
+		return c.String(http.StatusOK, "OK")
+	case err := <-errc:
+		return adminError(err)
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// GetJobs
+//
+//	@Summary      GetJobs
+//	@Description  list recognition jobs of all users, newest first, filtered by user_id, asr and status
+//	@Param        user_id query string false "Owner of the file"
+//	@Param        asr query string false "ASR name"
+//	@Param        status query string false "Job status"
+//	@Param        limit query int false "Page size, 100 by default, 1000 at most"
+//	@Param        offset query int false "Page offset"
+//	@Success      200 {object} array with jobs
+//	@Failure      204 {string} no data for an answer
+//	@Failure      400 {string} please check request params
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an administrator
+//	@Failure      500 {string} internal server error
+//	@Router       /api_admin/jobs [get]
+//
+//	@Security JWT Token
+func (lh *AdminHandler) GetJobs(c echo.Context) error {
+
+	ca := make(chan []adminapp.Job)
+	errc := make(chan error)
+
+	request := new(JobsRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind jobs request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		log.Errorf("error in bind jobs request. error: %v", err)
+		return err
+	}
+
+	go func() {
+
+		jobs, err := lh.AdminApp.GetJobs(c.Request().Context(), adminapp.JobFilter(*request))
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- jobs
+	}()
+
+	select {
+	case result := <-ca:
+		if len(result) == 0 {
+			return echo.NewHTTPError(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// SetJobStatus
+//
+//	@Summary      SetJobStatus
+//	@Description  move any user's job to NEW (requeue with reset attempts), FAILED or INVALID
+//	@Param        json body JobStatusRequest true "New status"
+//	@Success      200 {string} OK
+//	@Failure      400 {string} the status is not allowed
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an administrator
+//	@Failure      404 {string} no job with this uuid
+//	@Failure      409 {string} the file is already queued for this ASR
+//	@Failure      500 {string} internal server error
+//	@Router       /api_admin/jobs/:uuid/status [put]
+//
+//	@Security JWT Token
+func (lh *AdminHandler) SetJobStatus(c echo.Context) error {
+
+	ca := make(chan bool, 1)
+	errc := make(chan error)
+
+	request := new(JobStatusRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind job status request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		log.Errorf("error in bind job status request. error: %v", err)
+		return err
+	}
+
+	jobUUID := c.Param("uuid")
+
+	go func() {
+
+		if err := lh.AdminApp.SetJobStatus(c.Request().Context(), jobUUID, request.Status); err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- true
+	}()
+
+	select {
+	case <-ca:
+		return c.String(http.StatusOK, "OK")
+	case err := <-errc:
+		var errConflict *database.ConflictError
+		if errors.As(err, &errConflict) {
+			return c.String(http.StatusConflict, "the file is already queued for this ASR")
+		}
+		return adminError(err)
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+func adminError(err error) error {
+
+	switch {
+	case errors.Is(err, adminapp.ErrInvalidStatus), errors.Is(err, adminapp.ErrInvalidRole), errors.Is(err, adminapp.ErrSelfModification):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var errNotFound *database.NotFoundError
+	if errors.As(err, &errNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	log.Errorf("error: %v", err)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,270 @@
+package adminhandler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/adminapp"
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/userapp"
+	"github.com/RecoBattle/internal/controller/handler"
+	"github.com/RecoBattle/internal/controller/router"
+	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const ConfigASR = "../../../../cmd/config/config.toml"
+const adminID = "2d53b244-8844-40a6-ab37-e5b89019af0a"
+const otherUserID = "5f0c7e3a-1b2d-4c5e-8f9a-0b1c2d3e4f5a"
+const jobUUID = "7a1b2c3d-4e5f-4a6b-9c7d-8e9f0a1b2c3d"
+
+func getEchoContext(mockAdminStore *mocks.MockAdminStore, reqBody string) (echo.Context, *AdminHandler, config.ApiServer) {
+
+	var registeredHandlers []handler.Handler
+
+	cfg := config.NewConfig()
+
+	cnf, err := cfg.GetConfig(ConfigASR)
+	if err != nil {
+		log.Fatalf("cnf is not set. Error: %v", err)
+	}
+
+	userApp := userapp.NewUser(new(mocks.MockUserStore), cnf.ApiServer)
+
+	adminHandler := NewAdminHandler(adminapp.NewAdmin(mockAdminStore))
+	registeredHandlers = append(registeredHandlers, adminHandler)
+
+	e := router.NewRouter(cnf.ApiServer, registeredHandlers, userApp).Echo
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	c.Set("user", adminID)
+	c.Set("role", userapp.RoleAdmin)
+
+	return c, adminHandler, cnf.ApiServer
+}
+
+func accessToken(t *testing.T, cfg config.ApiServer, role string) string {
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &userapp.JWTCustomClaims{
+		UserID: adminID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+
+	signed, err := token.SignedString([]byte(cfg.SecretKeyForAccessToken))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	return signed
+}
+
+func TestAdminHandler_RequireRole(t *testing.T) {
+
+	mockAdminStore := new(mocks.MockAdminStore)
+	mockAdminStore.On("GetUsers", mock.Anything).Return([]adminapp.User{{UUID: adminID, Login: "admin", Role: userapp.RoleAdmin}}, nil)
+
+	c, _, cfg := getEchoContext(mockAdminStore, "")
+
+	serve := func(header, value string) int {
+
+		req := httptest.NewRequest(http.MethodGet, "/api_admin/users", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+
+		c.Echo().ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(echo.HeaderAuthorization, "Bearer "+accessToken(t, cfg, userapp.RoleAdmin)))
+	assert.Equal(t, http.StatusForbidden, serve(echo.HeaderAuthorization, "Bearer "+accessToken(t, cfg, userapp.RoleUser)))
+	// токены, выданные до появления ролей, роли не содержат
+	assert.Equal(t, http.StatusForbidden, serve(echo.HeaderAuthorization, "Bearer "+accessToken(t, cfg, "")))
+	// API-ключи в админском API не принимаются
+	assert.Equal(t, http.StatusUnauthorized, serve(router.HeaderAPIKey, "rb_0123456789abcdefghijklmnopqrstuvwxyzABCDE"))
+
+	mockAdminStore.AssertNumberOfCalls(t, "GetUsers", 1)
+}
+
+func TestAdminHandler_DisableUser(t *testing.T) {
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+		mockAdminStore.On("SetUserDisabled", mock.Anything, otherUserID, true).Return(nil)
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, "")
+		c.SetParamNames("id")
+		c.SetParamValues(otherUserID)
+
+		if assert.NoError(t, adminHandler.DisableUser(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("Enable", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+		mockAdminStore.On("SetUserDisabled", mock.Anything, otherUserID, false).Return(nil)
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, "")
+		c.SetParamNames("id")
+		c.SetParamValues(otherUserID)
+
+		if assert.NoError(t, adminHandler.EnableUser(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("Self", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, "")
+		c.SetParamNames("id")
+		c.SetParamValues(adminID)
+
+		err := adminHandler.DisableUser(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		mockAdminStore.AssertNotCalled(t, "SetUserDisabled", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+		mockAdminStore.On("SetUserDisabled", mock.Anything, otherUserID, true).Return(database.NewErrorNotFound(errors.New(otherUserID)))
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, "")
+		c.SetParamNames("id")
+		c.SetParamValues(otherUserID)
+
+		err := adminHandler.DisableUser(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestAdminHandler_SetUserRole(t *testing.T) {
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+		mockAdminStore.On("SetUserRole", mock.Anything, otherUserID, userapp.RoleAdmin).Return(nil)
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, `{"role": "admin"}`)
+		c.SetParamNames("id")
+		c.SetParamValues(otherUserID)
+
+		if assert.NoError(t, adminHandler.SetUserRole(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	for name, tc := range map[string]struct{ userID, body string }{
+		"Unknown role": {otherUserID, `{"role": "root"}`},
+		"Self demote":  {adminID, `{"role": "user"}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+
+			mockAdminStore := new(mocks.MockAdminStore)
+
+			c, adminHandler, _ := getEchoContext(mockAdminStore, tc.body)
+			c.SetParamNames("id")
+			c.SetParamValues(tc.userID)
+
+			err := adminHandler.SetUserRole(c)
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+			mockAdminStore.AssertNotCalled(t, "SetUserRole", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAdminHandler_GetJobs(t *testing.T) {
+
+	jobs := []adminapp.Job{{
+		AudioFile: audiofilesapp.AudioFile{UUID: uuid.MustParse(jobUUID), FileID: "file", ASR: "vosk", Status: audiofilesapp.StatusPROCESSING},
+		UserID:    otherUserID,
+		Login:     "operator",
+	}}
+
+	mockAdminStore := new(mocks.MockAdminStore)
+	mockAdminStore.On("GetJobs", mock.Anything, adminapp.JobFilter{Status: audiofilesapp.StatusPROCESSING, ASR: "vosk", Limit: 10}).Return(jobs, nil)
+
+	c, adminHandler, _ := getEchoContext(mockAdminStore, "")
+	c.SetRequest(httptest.NewRequest(http.MethodGet, "/api_admin/jobs?status=PROCESSING&asr=vosk&limit=10", nil))
+
+	if assert.NoError(t, adminHandler.GetJobs(c)) {
+		assert.Equal(t, http.StatusOK, c.Response().Status)
+
+		body := c.Response().Writer.(*httptest.ResponseRecorder).Body.String()
+		assert.Contains(t, body, `"user_id":"`+otherUserID+`"`)
+		assert.Contains(t, body, `"login":"operator"`)
+		assert.Contains(t, body, `"status":"PROCESSING"`)
+	}
+}
+
+func TestAdminHandler_SetJobStatus(t *testing.T) {
+
+	t.Run("Requeue", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+		mockAdminStore.On("SetJobStatus", mock.Anything, jobUUID, audiofilesapp.StatusNEW).Return(nil)
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, `{"status": "NEW"}`)
+		c.SetParamNames("uuid")
+		c.SetParamValues(jobUUID)
+
+		if assert.NoError(t, adminHandler.SetJobStatus(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("Not allowed status", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, `{"status": "PROCESSED"}`)
+		c.SetParamNames("uuid")
+		c.SetParamValues(jobUUID)
+
+		err := adminHandler.SetJobStatus(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Already queued", func(t *testing.T) {
+
+		mockAdminStore := new(mocks.MockAdminStore)
+		mockAdminStore.On("SetJobStatus", mock.Anything, jobUUID, audiofilesapp.StatusNEW).Return(database.NewErrorConflict(errors.New("duplicate")))
+
+		c, adminHandler, _ := getEchoContext(mockAdminStore, `{"status": "NEW"}`)
+		c.SetParamNames("uuid")
+		c.SetParamValues(jobUUID)
+
+		if assert.NoError(t, adminHandler.SetJobStatus(c)) {
+			assert.Equal(t, http.StatusConflict, c.Response().Status)
+		}
+	})
+}
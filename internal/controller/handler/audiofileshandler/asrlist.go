@@ -0,0 +1,53 @@
+package audiofileshandler
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const allASR = "*"
+
+// ASRList принимает в запросе одно имя ASR, массив имён или "*" для всех зарегистрированных ASR
+type ASRList []string
+
+func (l *ASRList) UnmarshalJSON(data []byte) error {
+
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*l = ASRList{name}
+		return nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return errors.New("asr must be a string or an array of strings")
+	}
+
+	*l = names
+
+	return nil
+}
+
+// resolve раскрывает "*" и убирает повторы, сохраняя порядок
+func (l ASRList) resolve(registered []string) []string {
+
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(l))
+
+	for _, name := range l {
+
+		expanded := []string{name}
+		if name == allASR {
+			expanded = registered
+		}
+
+		for _, n := range expanded {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,38 @@
+package audiofileshandler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASRList(t *testing.T) {
+
+	registered := []string{"vosk", "whisper", "yandexSpeachKit"}
+
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{name: "String", data: `"vosk"`, want: []string{"vosk"}},
+		{name: "Array", data: `["whisper", "vosk", "whisper"]`, want: []string{"whisper", "vosk"}},
+		{name: "All", data: `"*"`, want: registered},
+		{name: "All with duplicates", data: `["vosk", "*"]`, want: []string{"vosk", "whisper", "yandexSpeachKit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list ASRList
+			if assert.NoError(t, json.Unmarshal([]byte(tt.data), &list)) {
+				assert.Equal(t, tt.want, list.resolve(registered))
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		var list ASRList
+		assert.Error(t, json.Unmarshal([]byte(`{"asr": "vosk"}`), &list))
+	})
+}
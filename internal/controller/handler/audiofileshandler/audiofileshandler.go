@@ -1,73 +1,124 @@
 package audiofileshandler
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
-	"io"
+	"mime"
 	"net/http"
-	"os"
 
+	"github.com/RecoBattle/cmd/config"
 	"github.com/RecoBattle/internal/app/asr"
 	"github.com/RecoBattle/internal/app/audiofilesapp"
 	"github.com/RecoBattle/internal/controller/handler"
 	"github.com/RecoBattle/internal/database"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
-	"golang.org/x/sync/errgroup"
 )
 
 type AudioFilesHandler struct {
-	AudioFilesApp   *audiofilesapp.AudioFiles
-	ASRRegistry     *asr.ASRRegistry
-	PathFileStorage string
+	AudioFilesApp *audiofilesapp.AudioFiles
+	ASRRegistry   *asr.ASRRegistry
+	cfg           config.Upload
 }
 
-type RequestData struct {
+type RecognizeRequest struct {
 	ASR      string `json:"asr" validate:"required"`
-	FileName string `json:"file_name" validate:"required"`
-	Audio    string `json:"audio" validate:"required"`
+	Language string `json:"language"`
+}
+
+type RecognizeResponse struct {
+	UUID   string `json:"uuid"`
+	FileID string `json:"id_file"`
+	ASR    string `json:"asr"`
 }
 
-func NewAudioFilesHandler(audioFilesApp *audiofilesapp.AudioFiles, asrRegistry *asr.ASRRegistry, pathFileStorage string) *AudioFilesHandler {
-	return &AudioFilesHandler{AudioFilesApp: audioFilesApp, ASRRegistry: asrRegistry, PathFileStorage: pathFileStorage}
+type UploadResponse struct {
+	FileID string `json:"id_file"`
+	Jobs   []Job  `json:"jobs"`
+}
+
+type Job struct {
+	UUID string `json:"uuid"`
+	ASR  string `json:"asr"`
+}
+
+type RequestData struct {
+	ASR      ASRList `json:"asr" validate:"required,min=1,dive,required"`
+	FileName string  `json:"file_name" validate:"required"`
+	Audio    string  `json:"audio" validate:"required"`
+	Language string  `json:"language"`
+}
+
+func NewAudioFilesHandler(audioFilesApp *audiofilesapp.AudioFiles, asrRegistry *asr.ASRRegistry, cfg config.Upload) *AudioFilesHandler {
+	return &AudioFilesHandler{AudioFilesApp: audioFilesApp, ASRRegistry: asrRegistry, cfg: cfg}
 }
 
 func (lh *AudioFilesHandler) RegisterHandler(_ *echo.Echo, _, privateGroup *echo.Group) {
 
 	privateGroup.POST("/asr/audiofile", lh.SetAudioFile)
+	privateGroup.POST("/asr/audiofile/:id_file/recognize", lh.Recognize)
+	privateGroup.GET("/asr/audiofile/:id_file/audio", lh.GetAudio)
 	privateGroup.GET("/asr/audiofiles", lh.GetAudioFiles)
+	privateGroup.GET("/asr/stats", lh.GetStats)
 	privateGroup.GET("/asr/textfile/:uuid", lh.GetResultASR)
+	privateGroup.POST("/asr/job/:uuid/requeue", lh.RequeueASR)
+	privateGroup.GET("/user/usage", lh.GetUsage)
 }
 
 // SetAudioFile
 //
 //	@Summary      SetAudioFile
-//	@Description  add audio file
+//	@Description  add audio file as JSON with a base64 encoded wav, as multipart/form-data with an "audio" file part
+//	@Description  or as a raw audio/wav body with asr, file_name and language in the query string
+//	@Accept       json,mpfd,audio/wav
 //	@Param        json body RequestData
-//	@Success      202 {string} the new wav file has been accepted for processing
+//	@Success      202 {object} UploadResponse
 //	@Failure      400 {string} invalid request format
 //	@Failure      401 {string} the user is not authenticated
-//	@Failure      409 {string} wav file has already been uploaded by this user
+//	@Failure      413 {string} the audio file exceeds the upload size limit
+//	@Failure      415 {string} unsupported content type
 //	@Failure      422 {string} invalid ASR format or audio file type
+//	@Failure      429 {string} the jobs would exceed the audio minutes quota
 //	@Failure      500 {string} internal server error
 //	@Router       /api_private/asr/audiofile [post]
 //
 //	@Security JWT Token
 func (lh *AudioFilesHandler) SetAudioFile(c echo.Context) error {
 
-	ctx := c.Request().Context()
-
-	userID, err := handler.GetUserID(c)
+	scope, err := handler.GetScope(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
 
-	ca := make(chan bool)
-	inputAudiofile := make(chan audiofilesapp.AudioFile, 1)
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+
+	switch mediaType {
+	case echo.MIMEMultipartForm:
+		return lh.setAudioFileMultipart(c, scope)
+	case MIMEAudioWAV, "audio/x-wav", "audio/wave":
+		return lh.setAudioFileRaw(c, scope)
+	}
+
+	// Bind читает тело целиком, поэтому оно ограничивается до разбора, как и в потоковых вариантах
+	if c.Request().ContentLength > lh.maxJSONSize() {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, lh.tooLargeMessage())
+	}
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, lh.maxJSONSize())
 
 	audioFile := new(RequestData)
 	err = c.Bind(audioFile)
 	if err != nil {
 		log.Errorf("error in bind audio file request. error: %v", err)
+		var errTooLarge *http.MaxBytesError
+		if errors.As(err, &errTooLarge) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, lh.tooLargeMessage())
+		}
+		var errHTTP *echo.HTTPError
+		if errors.As(err, &errHTTP) && errHTTP.Code == http.StatusUnsupportedMediaType {
+			return err
+		}
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
@@ -76,73 +127,154 @@ func (lh *AudioFilesHandler) SetAudioFile(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	asr, ok := lh.ASRRegistry.GetService(audioFile.ASR)
-	if !ok {
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, "")
+	asrNames, err := lh.resolveASR(audioFile.ASR)
+	if err != nil {
+		return err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(audioFile.Audio)
+	if err != nil {
+		log.Errorf("error in decoding audio. error: %v", err)
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "audio must be a base64 encoded wav file")
 	}
 
-	go lh.AudioFilesApp.AddASRProcessing(ctx, asr, inputAudiofile)
+	if int64(len(data)) > lh.maxSize() {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, lh.tooLargeMessage())
+	}
 
 	newAudioFile := audiofilesapp.AudioFile{
-		FileName: audioFile.FileName,
-		ASR:      audioFile.ASR,
-		UserID:   userID,
+		FileName:    audioFile.FileName,
+		UserID:      scope.UserID,
+		WorkspaceID: scope.WorkspaceID,
+		Language:    audioFile.Language,
+		Data:        data,
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
-	g.Go(func() error {
+	return lh.upload(c, newAudioFile, asrNames, func(ctx context.Context) (string, error) {
+		return lh.AudioFilesApp.Create(ctx, newAudioFile, asrNames)
+	})
+}
 
-		filePath := lh.PathFileStorage + audioFile.FileName
-		if file, err := os.Create(filePath); err != nil {
-			return err
-		} else {
-			defer file.Close()
-			if _, err = io.WriteString(file, audioFile.Audio); err != nil {
-				return err
-			}
-		}
+// Recognize
+//
+//	@Summary      Recognize
+//	@Description  queue recognition of an already uploaded file with another ASR
+//	@Param        json body RecognizeRequest
+//	@Success      202 {object} RecognizeResponse
+//	@Failure      400 {string} invalid request format
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} the file was not found
+//	@Failure      409 {string} the file is already queued or recognized by this ASR
+//	@Failure      422 {string} invalid ASR
+//	@Failure      429 {string} the job would exceed the audio minutes quota
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/asr/audiofile/:id_file/recognize [post]
+//
+//	@Security JWT Token
+func (lh *AudioFilesHandler) Recognize(c echo.Context) error {
 
-		if data, err := os.ReadFile(filePath); err != nil {
-			return err
-		} else {
-			newAudioFile.Data = data
-		}
+	ca := make(chan string, 1)
+	errc := make(chan error)
 
-		newAudioFile.FileID, err = lh.AudioFilesApp.Create(ctx, newAudioFile)
-		if err != nil {
-			return err
-		}
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
 
-		inputAudiofile <- newAudioFile
-		ca <- true
+	fileID := c.Param("id_file")
 
-		close(inputAudiofile)
-		close(ca)
+	request := new(RecognizeRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind recognize request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
-		return nil
-	})
+	if err := c.Validate(request); err != nil {
+		log.Errorf("error in validate recognize request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, ok := lh.ASRRegistry.GetService(request.ASR); !ok {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "")
+	}
 
-	if err := g.Wait(); err != nil {
+	go func() {
+		jobUUID, err := lh.AudioFilesApp.Recognize(c.Request().Context(), scope, fileID, request.ASR, request.Language)
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- jobUUID
+	}()
+
+	select {
+	case jobUUID := <-ca:
+		return c.JSON(http.StatusAccepted, RecognizeResponse{UUID: jobUUID, FileID: fileID, ASR: request.ASR})
+	case err := <-errc:
 		log.Errorf("error: %v", err)
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
 		var errConflict *database.ConflictError
 		if errors.As(err, &errConflict) {
-			return c.String(http.StatusConflict, "wav file has already been uploaded by this user")
+			return c.String(http.StatusConflict, "the file is already queued or recognized by this ASR")
+		}
+		if errors.Is(err, audiofilesapp.ErrQuotaExceeded) {
+			return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
 	}
+}
 
-	select {
-	case <-ca:
-		return c.String(http.StatusAccepted, "OK")
-	case <-ctx.Done():
-		return nil
+// GetAudio
+//
+//	@Summary      GetAudio
+//	@Description  download the original wav file, a Range header returns only the requested part
+//	@Param        Range header string false "bytes=<start>-<end>"
+//	@Success      200 {file} audio/wav
+//	@Success      206 {file} the requested part of the wav file
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} the file was not found
+//	@Failure      416 {string} the range is not satisfiable
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/asr/audiofile/:id_file/audio [get]
+//
+//	@Security JWT Token
+func (lh *AudioFilesHandler) GetAudio(c echo.Context) error {
+
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+
+	file, content, err := lh.AudioFilesApp.OpenAudio(c.Request().Context(), scope, c.Param("id_file"))
+	if err != nil {
+		log.Errorf("error: %v", err)
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	defer content.Close()
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEAudioWAV)
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{"filename": file.FileName}))
+
+	http.ServeContent(c.Response(), c.Request(), file.FileName, file.UploadedAt, content)
+
+	return nil
 }
 
 // GetAudioFiles
 //
 //	@Summary      GetAudioFiles
-//	@Description  get all files from DB
+//	@Description  get all files from DB with the state, cost and timings of their recognition jobs
 //	@Success      200 {object} an array of uploaded wav files
 //	@Failure      204 {string} no data for an answer
 //	@Failure      401 {string} the user is not authenticated
@@ -155,13 +287,13 @@ func (lh *AudioFilesHandler) GetAudioFiles(c echo.Context) error {
 	ca := make(chan []audiofilesapp.AudioFile, 1)
 	errc := make(chan error)
 
-	userID, err := handler.GetUserID(c)
+	scope, err := handler.GetScope(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
 
 	go func() {
-		outputData, err := lh.AudioFilesApp.GetAudioFiles(c.Request().Context(), userID)
+		outputData, err := lh.AudioFilesApp.GetAudioFiles(c.Request().Context(), scope)
 
 		if err != nil {
 			errc <- err
@@ -185,6 +317,94 @@ func (lh *AudioFilesHandler) GetAudioFiles(c echo.Context) error {
 	}
 }
 
+// GetStats
+//
+//	@Summary      GetStats
+//	@Description  percentiles of the real-time factor, processing time, ASR response time and queue wait of recognized jobs per ASR
+//	@Success      200 {object} array with stats for each ASR
+//	@Failure      204 {string} no recognized jobs with timings
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/asr/stats [get]
+//
+//	@Security JWT Token
+func (lh *AudioFilesHandler) GetStats(c echo.Context) error {
+
+	ca := make(chan []audiofilesapp.ASRStats, 1)
+	errc := make(chan error)
+
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	go func() {
+		stats, err := lh.AudioFilesApp.GetStats(c.Request().Context(), scope)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- stats
+	}()
+
+	select {
+	case result := <-ca:
+		if len(result) == 0 {
+			return echo.NewHTTPError(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// GetUsage
+//
+//	@Summary      GetUsage
+//	@Description  audio minutes queued by the user in the current quota period, per ASR and in total, with the limits
+//	@Success      200 {object} UsageReport
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/user/usage [get]
+//
+//	@Security JWT Token
+func (lh *AudioFilesHandler) GetUsage(c echo.Context) error {
+
+	ca := make(chan *audiofilesapp.UsageReport, 1)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	go func() {
+		report, err := lh.AudioFilesApp.GetUsage(c.Request().Context(), userID)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- report
+	}()
+
+	select {
+	case result := <-ca:
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
 // GetResultASR
 //
 //	@Summary      GetResultASR
@@ -192,6 +412,7 @@ func (lh *AudioFilesHandler) GetAudioFiles(c echo.Context) error {
 //	@Success      200 {object} array with recognized text
 //	@Failure      204 {string} no data for an answer
 //	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} no job with this uuid among the user's files
 //	@Failure      500 {string} internal server error
 //	@Router       /api_private/asr/textfile/:uuid [get]
 //
@@ -201,10 +422,15 @@ func (lh *AudioFilesHandler) GetResultASR(c echo.Context) error {
 	ca := make(chan []audiofilesapp.ResultASR, 1)
 	errc := make(chan error)
 
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
 	uuid := c.Param("uuid")
 
 	go func() {
-		outputData, err := lh.AudioFilesApp.GetResultASR(c.Request().Context(), uuid)
+		outputData, err := lh.AudioFilesApp.GetResultASR(c.Request().Context(), scope, uuid)
 
 		if err != nil {
 			errc <- err
@@ -222,6 +448,61 @@ func (lh *AudioFilesHandler) GetResultASR(c echo.Context) error {
 		return c.JSON(http.StatusOK, result)
 	case err := <-errc:
 		log.Errorf("error: %v", err)
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// RequeueASR
+//
+//	@Summary      RequeueASR
+//	@Description  requeue failed or invalid recognition job
+//	@Success      202 {string} the job has been queued again
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} no failed job with this uuid
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/asr/job/:uuid/requeue [post]
+//
+//	@Security JWT Token
+func (lh *AudioFilesHandler) RequeueASR(c echo.Context) error {
+
+	ca := make(chan bool, 1)
+	errc := make(chan error)
+
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	uuid := c.Param("uuid")
+
+	go func() {
+		if err := lh.AudioFilesApp.Requeue(c.Request().Context(), scope, uuid); err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- true
+	}()
+
+	select {
+	case <-ca:
+		return c.String(http.StatusAccepted, "OK")
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		var errConflict *database.ConflictError
+		if errors.As(err, &errConflict) {
+			return c.String(http.StatusConflict, "the file is already queued for this ASR")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	case <-c.Request().Context().Done():
 		return nil
@@ -1,25 +1,37 @@
 package audiofileshandler
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/RecoBattle/cmd/config"
 	"github.com/RecoBattle/internal/app/asr"
+	"github.com/RecoBattle/internal/app/asr/vosk"
 	yandexspeachkit "github.com/RecoBattle/internal/app/asr/yandexSpeachKit"
 	"github.com/RecoBattle/internal/app/audiofilesapp"
 	"github.com/RecoBattle/internal/app/userapp"
+	"github.com/RecoBattle/internal/app/wav"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/RecoBattle/internal/controller/handler"
 	"github.com/RecoBattle/internal/controller/router"
 	"github.com/RecoBattle/internal/database"
 	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/RecoBattle/internal/storage"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -27,10 +39,21 @@ import (
 )
 
 const ConfigASR = "../../../../cmd/config/config.toml"
-const PathTestFile = "../../../../testfile/test.wav"
+const PathTestFile = "../../../../cmd/recobattle/test.wav"
 const userID = "2d53b244-8844-40a6-ab37-e5b89019af0a"
 
-func getEchoContext(mockAudioFileStore *mocks.MockAudioFileStore, reqBody string) (echo.Context, *AudioFilesHandler) {
+// getEchoContext хендлер с локальным хранилищем во временном каталоге теста, который удаляется после теста
+func getEchoContext(t *testing.T, mockAudioFileStore *mocks.MockAudioFileStore, reqBody string) (echo.Context, *AudioFilesHandler) {
+
+	fileStorage, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		log.Fatalf("Error creating file storage: %v", err)
+	}
+
+	return getEchoContextWithStorage(mockAudioFileStore, fileStorage, reqBody)
+}
+
+func getEchoContextWithStorage(mockAudioFileStore *mocks.MockAudioFileStore, fileStorage storage.Storage, reqBody string) (echo.Context, *AudioFilesHandler) {
 
 	var registeredHandlers []handler.Handler
 
@@ -50,8 +73,11 @@ func getEchoContext(mockAudioFileStore *mocks.MockAudioFileStore, reqBody string
 	yandexASR := yandexspeachkit.NewYandexASRStore(cnf.YandexAsr)
 	asrRegistry.AddService("yandexSpeachKit", yandexASR)
 
-	audiofilesApp := audiofilesapp.NewAudioFile(mockAudioFileStore)
-	audiofilesHandler := NewAudioFilesHandler(audiofilesApp, &asrRegistry, cfg.PathFileStorage)
+	voskASR := vosk.NewVoskASRStore(cnf.VoskAsr)
+	asrRegistry.AddService("vosk", voskASR)
+
+	audiofilesApp := audiofilesapp.NewAudioFile(mockAudioFileStore, &asrRegistry, fileStorage, cnf.Queue, cnf.Quota, nil)
+	audiofilesHandler := NewAudioFilesHandler(audiofilesApp, &asrRegistry, cnf.Upload)
 
 	registeredHandlers = append(registeredHandlers, audiofilesHandler)
 
@@ -82,16 +108,63 @@ func getAudiofile() audiofilesapp.AudioFile {
 	return audioFile
 }
 
+// queued задания, которые загрузка ставит в очередь
+func queued(jobs ...audiofilesapp.AudioFile) []audiofilesapp.AudioFile {
+
+	result := make([]audiofilesapp.AudioFile, 0, len(jobs))
+	for _, job := range jobs {
+		job.Status = audiofilesapp.StatusNEW
+		result = append(result, job)
+	}
+
+	return result
+}
+
 func TestAudioFilesHandler_SetAudioFile(t *testing.T) {
 
+	data, err := os.ReadFile(PathTestFile)
+	if err != nil {
+		log.Fatalf("Error reading file: %s", err)
+	}
+
+	audioBase64 := strings.TrimSpace(string(data))
+
+	audio, err := base64.StdEncoding.DecodeString(audioBase64)
+	if err != nil {
+		log.Fatalf("Error decoding file: %s", err)
+	}
+
+	info, err := wav.Decode(audio)
+	if err != nil {
+		log.Fatalf("Error parsing file: %s", err)
+	}
+
+	contentHash, err := info.ContentHash(bytes.NewReader(audio))
+	if err != nil {
+		log.Fatalf("Error hashing file: %s", err)
+	}
+
+	rawHash := sha256.Sum256(audio)
+	fileHash := sha256.Sum256([]byte(userID + ":" + contentHash))
+
 	audioFile := getAudiofile()
+	audioFile.FileID = hex.EncodeToString(fileHash[:])
+
+	storedFile := audioFile
+	storedFile.ASR = ""
+	storedFile.Audio = *info
+	storedFile.Hash = contentHash
+	storedFile.StorageKey = hex.EncodeToString(rawHash[:]) + ".wav"
 	reqBody := `{"asr": "yandexSpeachKit", "file_name": "testfile.wav", "audio":""}`
 
+	notFound := database.NewErrorNotFound(errors.New(audioFile.FileID))
+
 	mockAudioFileStore := new(mocks.MockAudioFileStore)
-	mockAudioFileStore.On("CreateFile", mock.Anything, audioFile).Return(nil)
-	mockAudioFileStore.On("CreateASR", mock.Anything, audioFile).Return(nil)
+	mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), audioFile.FileID).Return((*audiofilesapp.AudioFile)(nil), notFound)
+	mockAudioFileStore.On("CreateFile", mock.Anything, storedFile).Return(nil)
+	mockAudioFileStore.On("CreateASRs", mock.Anything, queued(audioFile)).Return(queued(audioFile), nil)
 
-	c, audiofilesHandler := getEchoContext(mockAudioFileStore, reqBody)
+	c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, reqBody)
 
 	t.Run("Bad request", func(t *testing.T) {
 
@@ -102,26 +175,79 @@ func TestAudioFilesHandler_SetAudioFile(t *testing.T) {
 
 	})
 
-	data, err := os.ReadFile(PathTestFile)
-	if err != nil {
-		log.Fatalf("Error reading file: %s", err)
-	}
-
-	audioBase64 := base64.StdEncoding.EncodeToString(data)
-	audioFile.Data = data
-
 	reqBody = fmt.Sprintf(`{"asr": "yandexSpeachKit", "file_name": "testfile.wav", "audio":"%s"}`, audioBase64)
 
-	c, audiofilesHandler = getEchoContext(mockAudioFileStore, reqBody)
+	c, audiofilesHandler = getEchoContext(t, mockAudioFileStore, reqBody)
 
 	t.Run("Successful", func(t *testing.T) {
 
 		if assert.NoError(t, audiofilesHandler.SetAudioFile(c)) {
 			assert.Equal(t, http.StatusAccepted, c.Response().Status)
+
+			var response UploadResponse
+			assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+			assert.Equal(t, audioFile.FileID, response.FileID)
+			if assert.Len(t, response.Jobs, 1) {
+				assert.Equal(t, "yandexSpeachKit", response.Jobs[0].ASR)
+				assert.NotEmpty(t, response.Jobs[0].UUID)
+			}
 		}
 
 	})
 
+	t.Run("Fan-out", func(t *testing.T) {
+
+		voskJob := audioFile
+		voskJob.ASR = "vosk"
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), audioFile.FileID).Return((*audiofilesapp.AudioFile)(nil), notFound)
+		mockAudioFileStore.On("CreateFile", mock.Anything, storedFile).Return(nil)
+		// порядок заданий — порядок ASR в запросе, "*" раскрывается в отсортированный список
+		mockAudioFileStore.On("CreateASRs", mock.Anything, queued(audioFile, voskJob)).Return(queued(audioFile, voskJob), nil)
+		mockAudioFileStore.On("CreateASRs", mock.Anything, queued(voskJob, audioFile)).Return(queued(voskJob, audioFile), nil)
+
+		for _, asrField := range []string{`["yandexSpeachKit", "vosk", "yandexSpeachKit"]`, `"*"`} {
+
+			reqBodyFanOut := fmt.Sprintf(`{"asr": %s, "file_name": "testfile.wav", "audio":"%s"}`, asrField, audioBase64)
+
+			c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, reqBodyFanOut)
+
+			if assert.NoError(t, audiofilesHandler.SetAudioFile(c)) {
+				assert.Equal(t, http.StatusAccepted, c.Response().Status)
+
+				var response UploadResponse
+				assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+				asrNames := make([]string, 0, len(response.Jobs))
+				for _, job := range response.Jobs {
+					asrNames = append(asrNames, job.ASR)
+				}
+				assert.ElementsMatch(t, []string{"yandexSpeachKit", "vosk"}, asrNames)
+			}
+		}
+	})
+
+	t.Run("Empty ASR list", func(t *testing.T) {
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, fmt.Sprintf(`{"asr": [], "file_name": "testfile.wav", "audio":"%s"}`, audioBase64))
+
+		err := audiofilesHandler.SetAudioFile(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Invalid audio", func(t *testing.T) {
+
+		for _, audio := range []string{"not base64", base64.StdEncoding.EncodeToString([]byte("RIFF but not a wav file"))} {
+
+			c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, fmt.Sprintf(`{"asr": "yandexSpeachKit", "file_name": "testfile.wav", "audio":"%s"}`, audio))
+
+			err := audiofilesHandler.SetAudioFile(c)
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, err.(*echo.HTTPError).Code)
+		}
+	})
+
 	t.Run("Unauthorized", func(t *testing.T) {
 
 		c.Set("user", "")
@@ -135,7 +261,7 @@ func TestAudioFilesHandler_SetAudioFile(t *testing.T) {
 
 		reqBodyIncorrect := fmt.Sprintf(`{"asr": "3iTech", "file_name": "testfile.wav", "audio":"%s"}`, audioBase64)
 
-		c, audiofilesHandler := getEchoContext(mockAudioFileStore, reqBodyIncorrect)
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, reqBodyIncorrect)
 
 		err := audiofilesHandler.SetAudioFile(c)
 		assert.Error(t, err)
@@ -143,15 +269,289 @@ func TestAudioFilesHandler_SetAudioFile(t *testing.T) {
 		assert.Equal(t, http.StatusUnprocessableEntity, httpError.Code)
 	})
 
-	t.Run("Conflict", func(t *testing.T) {
+	t.Run("Same audio uploaded again", func(t *testing.T) {
+
+		existing := storedFile
+		existing.FileName = "first name.wav"
+
+		voskJob := audioFile
+		voskJob.ASR = "vosk"
 
 		mockAudioFileStore := new(mocks.MockAudioFileStore)
-		mockAudioFileStore.On("CreateFile", mock.Anything, audioFile).Return(database.NewErrorConflict(errors.New("409")))
+		mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), audioFile.FileID).Return(&existing, nil)
+		// yandexSpeachKit уже распознаёт этот файл, хранилище пропускает его задание
+		mockAudioFileStore.On("CreateASRs", mock.Anything, queued(audioFile, voskJob)).Return(queued(voskJob), nil)
 
-		c, audiofilesHandler := getEchoContext(mockAudioFileStore, reqBody)
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, fmt.Sprintf(`{"asr": ["yandexSpeachKit", "vosk"], "file_name": "testfile.wav", "audio":"%s"}`, audioBase64))
 
 		if assert.NoError(t, audiofilesHandler.SetAudioFile(c)) {
-			assert.Equal(t, http.StatusConflict, c.Response().Status)
+			assert.Equal(t, http.StatusAccepted, c.Response().Status)
+
+			var response UploadResponse
+			assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+			assert.Equal(t, audioFile.FileID, response.FileID)
+			if assert.Len(t, response.Jobs, 1) {
+				assert.Equal(t, "vosk", response.Jobs[0].ASR)
+			}
+		}
+
+		mockAudioFileStore.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Same audio with other metadata", func(t *testing.T) {
+
+		// та же запись с блоком LIST/INFO перед блоком данных
+		tagged := append([]byte{}, audio[:info.DataOffset-8]...)
+		tagged = append(tagged, "LIST\x04\x00\x00\x00INFO"...)
+		tagged = append(tagged, audio[info.DataOffset-8:]...)
+
+		existing := storedFile
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), audioFile.FileID).Return(&existing, nil)
+		mockAudioFileStore.On("CreateASRs", mock.Anything, queued(audioFile)).Return([]audiofilesapp.AudioFile{}, nil)
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, fmt.Sprintf(`{"asr": "yandexSpeachKit", "file_name": "testfile.wav", "audio":"%s"}`,
+			base64.StdEncoding.EncodeToString(tagged)))
+
+		if assert.NoError(t, audiofilesHandler.SetAudioFile(c)) {
+			assert.Equal(t, http.StatusAccepted, c.Response().Status)
+
+			var response UploadResponse
+			assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+			assert.Equal(t, audioFile.FileID, response.FileID)
+		}
+
+		mockAudioFileStore.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Multipart", func(t *testing.T) {
+
+		voskJob := audioFile
+		voskJob.ASR = "vosk"
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), audioFile.FileID).Return((*audiofilesapp.AudioFile)(nil), notFound)
+		mockAudioFileStore.On("CreateFile", mock.Anything, storedFile).Return(nil)
+		mockAudioFileStore.On("CreateASRs", mock.Anything, queued(audioFile, voskJob)).Return(queued(audioFile, voskJob), nil)
+
+		body, contentType := multipartBody(t, map[string][]string{"asr": {"yandexSpeachKit", "vosk"}}, "testfile.wav", audio)
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+		setRequest(c, http.MethodPost, "/api_private/asr/audiofile", body, contentType)
+
+		if assert.NoError(t, audiofilesHandler.SetAudioFile(c)) {
+			assert.Equal(t, http.StatusAccepted, c.Response().Status)
+
+			var response UploadResponse
+			assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+			assert.Equal(t, audioFile.FileID, response.FileID)
+			assert.Len(t, response.Jobs, 2)
+		}
+
+		mockAudioFileStore.AssertCalled(t, "CreateFile", mock.Anything, storedFile)
+	})
+
+	t.Run("Multipart without audio part", func(t *testing.T) {
+
+		body, contentType := multipartBody(t, map[string][]string{"asr": {"vosk"}, "file_name": {"testfile.wav"}}, "", nil)
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+		setRequest(c, http.MethodPost, "/api_private/asr/audiofile", body, contentType)
+
+		err := audiofilesHandler.SetAudioFile(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Raw audio/wav", func(t *testing.T) {
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+		setRequest(c, http.MethodPost, "/api_private/asr/audiofile?asr=yandexSpeachKit&file_name=testfile.wav", bytes.NewReader(audio), MIMEAudioWAV)
+
+		if assert.NoError(t, audiofilesHandler.SetAudioFile(c)) {
+			assert.Equal(t, http.StatusAccepted, c.Response().Status)
+
+			var response UploadResponse
+			assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+			assert.Equal(t, audioFile.FileID, response.FileID)
+			if assert.Len(t, response.Jobs, 1) {
+				assert.Equal(t, "yandexSpeachKit", response.Jobs[0].ASR)
+			}
+		}
+	})
+
+	t.Run("Raw audio/wav without ASR", func(t *testing.T) {
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+		setRequest(c, http.MethodPost, "/api_private/asr/audiofile?file_name=testfile.wav", bytes.NewReader(audio), MIMEAudioWAV)
+
+		err := audiofilesHandler.SetAudioFile(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+
+		large := append(append([]byte{}, audio...), make([]byte, 1<<20)...)
+
+		multipart, contentType := multipartBody(t, map[string][]string{"asr": {"vosk"}}, "testfile.wav", large)
+
+		requests := []struct {
+			target      string
+			body        io.Reader
+			contentType string
+		}{
+			{target: "/api_private/asr/audiofile?asr=vosk&file_name=testfile.wav", body: bytes.NewReader(large), contentType: MIMEAudioWAV},
+			{target: "/api_private/asr/audiofile?asr=vosk&file_name=testfile.wav", body: io.MultiReader(bytes.NewReader(large)), contentType: MIMEAudioWAV},
+			{target: "/api_private/asr/audiofile", body: multipart, contentType: contentType},
+			{target: "/api_private/asr/audiofile", body: strings.NewReader(fmt.Sprintf(`{"asr": "vosk", "file_name": "testfile.wav", "audio":"%s"}`,
+				base64.StdEncoding.EncodeToString(large))), contentType: echo.MIMEApplicationJSON},
+			// без Content-Length тело JSON обрывается на лимите, не дойдя до декодирования base64
+			{target: "/api_private/asr/audiofile", body: io.MultiReader(strings.NewReader(fmt.Sprintf(`{"asr": "vosk", "file_name": "testfile.wav", "audio":"%s"}`,
+				base64.StdEncoding.EncodeToString(large)))), contentType: echo.MIMEApplicationJSON},
+		}
+
+		for _, r := range requests {
+
+			mockAudioFileStore := new(mocks.MockAudioFileStore)
+
+			c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+			audiofilesHandler.cfg.MaxSize = 1
+			setRequest(c, http.MethodPost, r.target, r.body, r.contentType)
+
+			err := audiofilesHandler.SetAudioFile(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, http.StatusRequestEntityTooLarge, err.(*echo.HTTPError).Code)
+			}
+
+			mockAudioFileStore.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+		}
+	})
+}
+
+func setRequest(c echo.Context, method, target string, body io.Reader, contentType string) {
+
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set(echo.HeaderContentType, contentType)
+	c.SetRequest(req)
+}
+
+func multipartBody(t *testing.T, fields map[string][]string, fileName string, audio []byte) (io.Reader, string) {
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	for name, values := range fields {
+		for _, value := range values {
+			assert.NoError(t, writer.WriteField(name, value))
+		}
+	}
+
+	if audio != nil {
+		part, err := writer.CreateFormFile("audio", fileName)
+		assert.NoError(t, err)
+		_, err = part.Write(audio)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, writer.Close())
+
+	return body, writer.FormDataContentType()
+}
+
+func TestAudioFilesHandler_GetAudio(t *testing.T) {
+
+	content := []byte("RIFF0123456789")
+
+	fileStorage, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		log.Fatalf("Error creating file storage: %v", err)
+	}
+
+	if err = fileStorage.Put(context.Background(), "abc.wav", bytes.NewReader(content), int64(len(content))); err != nil {
+		log.Fatalf("Error writing file: %v", err)
+	}
+
+	audioFile := getAudiofile()
+	audioFile.StorageKey = "abc.wav"
+
+	missing := getAudiofile()
+	missing.FileID = "missing"
+	missing.StorageKey = "missing.wav"
+
+	otherUserID := "5f0c7e3a-1b2d-4c5e-8f9a-0b1c2d3e4f5a"
+
+	mockAudioFileStore := new(mocks.MockAudioFileStore)
+	mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), audioFile.FileID).Return(&audioFile, nil)
+	mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), missing.FileID).Return(&missing, nil)
+	mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(otherUserID), audioFile.FileID).Return((*audiofilesapp.AudioFile)(nil), database.NewErrorNotFound(errors.New("404")))
+
+	request := func(user, fileID, rangeHeader string) (echo.Context, error) {
+
+		c, audiofilesHandler := getEchoContextWithStorage(mockAudioFileStore, fileStorage, "")
+		setRequest(c, http.MethodGet, "/api_private/asr/audiofile/"+fileID+"/audio", nil, "")
+		if rangeHeader != "" {
+			c.Request().Header.Set("Range", rangeHeader)
+		}
+
+		c.Set("user", user)
+		c.SetParamNames("id_file")
+		c.SetParamValues(fileID)
+
+		return c, audiofilesHandler.GetAudio(c)
+	}
+
+	t.Run("Successful", func(t *testing.T) {
+
+		c, err := request(userID, audioFile.FileID, "")
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+			assert.Equal(t, MIMEAudioWAV, c.Response().Header().Get(echo.HeaderContentType))
+			assert.Equal(t, "bytes", c.Response().Header().Get("Accept-Ranges"))
+			assert.Equal(t, content, c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes())
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+
+		c, err := request(userID, audioFile.FileID, "bytes=4-7")
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusPartialContent, c.Response().Status)
+			assert.Equal(t, "bytes 4-7/14", c.Response().Header().Get("Content-Range"))
+			assert.Equal(t, "0123", c.Response().Writer.(*httptest.ResponseRecorder).Body.String())
+		}
+	})
+
+	t.Run("Range not satisfiable", func(t *testing.T) {
+
+		c, err := request(userID, audioFile.FileID, "bytes=100-200")
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, c.Response().Status)
+		}
+	})
+
+	t.Run("Another user's file", func(t *testing.T) {
+
+		_, err := request(otherUserID, audioFile.FileID, "")
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		}
+	})
+
+	t.Run("Missing in storage", func(t *testing.T) {
+
+		_, err := request(userID, missing.FileID, "")
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		_, err := request("", audioFile.FileID, "")
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
 		}
 	})
 }
@@ -161,9 +561,9 @@ func TestAudioFilesHandler_GetAudioFiles(t *testing.T) {
 	var files []audiofilesapp.AudioFile
 
 	mockAudioFileStore := new(mocks.MockAudioFileStore)
-	mockAudioFileStore.On("GetAudioFiles", mock.Anything, userID).Return(&files, nil)
+	mockAudioFileStore.On("GetAudioFiles", mock.Anything, workspaceapp.Personal(userID)).Return(&files, nil)
 
-	c, audiofilesHandler := getEchoContext(mockAudioFileStore, "")
+	c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
 
 	t.Run("No content", func(t *testing.T) {
 
@@ -179,9 +579,9 @@ func TestAudioFilesHandler_GetAudioFiles(t *testing.T) {
 	t.Run("Successful", func(t *testing.T) {
 
 		mockAudioFileStore := new(mocks.MockAudioFileStore)
-		mockAudioFileStore.On("GetAudioFiles", mock.Anything, userID).Return(&files, nil)
+		mockAudioFileStore.On("GetAudioFiles", mock.Anything, workspaceapp.Personal(userID)).Return(&files, nil)
 
-		c, audiofilesHandler := getEchoContext(mockAudioFileStore, "")
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
 
 		if assert.NoError(t, audiofilesHandler.GetAudioFiles(c)) {
 			assert.Equal(t, http.StatusOK, c.Response().Status)
@@ -200,14 +600,119 @@ func TestAudioFilesHandler_GetAudioFiles(t *testing.T) {
 	})
 }
 
+func TestAudioFilesHandler_GetUsage(t *testing.T) {
+
+	mockAudioFileStore := new(mocks.MockAudioFileStore)
+	mockAudioFileStore.On("GetUsage", mock.Anything, userID, mock.Anything).Return([]audiofilesapp.Usage{{ASR: "vosk", Jobs: 2, Seconds: 150}}, nil)
+
+	c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+
+	t.Run("Successful", func(t *testing.T) {
+
+		if assert.NoError(t, audiofilesHandler.GetUsage(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+
+			var report audiofilesapp.UsageReport
+			if assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &report)) {
+				assert.Equal(t, 2.5, report.Total.Minutes)
+				assert.Equal(t, []audiofilesapp.Usage{{ASR: "vosk", Jobs: 2, Minutes: 2.5}}, report.ASR)
+			}
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c.Set("user", nil)
+
+		err := audiofilesHandler.GetUsage(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestAudioFilesHandler_GetStats(t *testing.T) {
+
+	t.Run("No content", func(t *testing.T) {
+
+		files := []audiofilesapp.AudioFile{getAudiofile()}
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetAudioFiles", mock.Anything, workspaceapp.Personal(userID)).Return(&files, nil)
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+
+		err := audiofilesHandler.GetStats(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNoContent, err.(*echo.HTTPError).Code)
+	})
+
+	queuedAt := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	startedAt := queuedAt.Add(time.Second)
+	finishedAt := startedAt.Add(6 * time.Second)
+	upstream := 5.0
+
+	audioFile := getAudiofile()
+	audioFile.Status = audiofilesapp.StatusPROCESSED
+	audioFile.Audio = wav.Info{Duration: 60}
+	audioFile.Timings = &audiofilesapp.Timings{QueuedAt: queuedAt, StartedAt: &startedAt, FinishedAt: &finishedAt, UpstreamSeconds: &upstream}
+
+	files := []audiofilesapp.AudioFile{audioFile}
+
+	mockAudioFileStore := new(mocks.MockAudioFileStore)
+	mockAudioFileStore.On("GetAudioFiles", mock.Anything, workspaceapp.Personal(userID)).Return(&files, nil)
+
+	c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+
+	t.Run("Successful", func(t *testing.T) {
+
+		if assert.NoError(t, audiofilesHandler.GetStats(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+
+			var stats []audiofilesapp.ASRStats
+			if assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &stats)) && assert.Len(t, stats, 1) {
+				assert.Equal(t, "yandexSpeachKit", stats[0].ASR)
+				assert.Equal(t, 1, stats[0].Jobs)
+				assert.Equal(t, 0.1, stats[0].RTF.P99)
+				assert.Equal(t, 5.0, stats[0].UpstreamSeconds.P50)
+			}
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c.Set("user", nil)
+
+		err := audiofilesHandler.GetStats(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
+}
+
 func TestAudioFilesHandler_GetResultASR(t *testing.T) {
 
 	var resASR []audiofilesapp.ResultASR
 
+	jobUUID := "2d53b244-8844-40a6-ab37-e5b89019af0a"
+	otherUserID := "5f0c7e3a-1b2d-4c5e-8f9a-0b1c2d3e4f5a"
+
+	getContext := func(mockAudioFileStore *mocks.MockAudioFileStore, user string) (echo.Context, *AudioFilesHandler) {
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+		c.Set("user", user)
+		c.SetParamNames("uuid")
+		c.SetParamValues(jobUUID)
+
+		return c, audiofilesHandler
+	}
+
+	// задание файла, загруженного userID в личное пространство
+	job := &audiofilesapp.AudioFile{UUID: uuid.MustParse(jobUUID), FileID: "file", ASR: "yandexSpeachKit", UserID: userID}
+
 	mockAudioFileStore := new(mocks.MockAudioFileStore)
-	mockAudioFileStore.On("GetResultASR", mock.Anything, userID).Return(&resASR, nil)
+	mockAudioFileStore.On("GetASR", mock.Anything, jobUUID).Return(job, nil)
+	mockAudioFileStore.On("GetResultASR", mock.Anything, jobUUID).Return(&resASR, nil)
 
-	c, audiofilesHandler := getEchoContext(mockAudioFileStore, "")
+	c, audiofilesHandler := getContext(mockAudioFileStore, userID)
 
 	t.Run("No content", func(t *testing.T) {
 
@@ -228,12 +733,159 @@ func TestAudioFilesHandler_GetResultASR(t *testing.T) {
 	t.Run("Successful", func(t *testing.T) {
 
 		mockAudioFileStore := new(mocks.MockAudioFileStore)
-		mockAudioFileStore.On("GetResultASR", mock.Anything, userID).Return(&resASR, nil)
+		mockAudioFileStore.On("GetASR", mock.Anything, jobUUID).Return(job, nil)
+		mockAudioFileStore.On("GetResultASR", mock.Anything, jobUUID).Return(&resASR, nil)
 
-		c, audiofilesHandler := getEchoContext(mockAudioFileStore, "")
+		c, audiofilesHandler := getContext(mockAudioFileStore, userID)
 
 		if assert.NoError(t, audiofilesHandler.GetResultASR(c)) {
 			assert.Equal(t, http.StatusOK, c.Response().Status)
 		}
 	})
+
+	t.Run("Another user's job", func(t *testing.T) {
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetASR", mock.Anything, jobUUID).Return(job, nil)
+		mockAudioFileStore.On("GetResultASR", mock.Anything, jobUUID).Return(&resASR, nil)
+
+		c, audiofilesHandler := getContext(mockAudioFileStore, otherUserID)
+
+		err := audiofilesHandler.GetResultASR(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		mockAudioFileStore.AssertNotCalled(t, "GetResultASR", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetASR", mock.Anything, jobUUID).Return((*audiofilesapp.AudioFile)(nil), database.NewErrorNotFound(errors.New(jobUUID)))
+
+		c, audiofilesHandler := getContext(mockAudioFileStore, userID)
+
+		err := audiofilesHandler.GetResultASR(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c, audiofilesHandler := getContext(new(mocks.MockAudioFileStore), "")
+
+		err := audiofilesHandler.GetResultASR(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestAudioFilesHandler_RequeueASR(t *testing.T) {
+
+	jobUUID := "2d53b244-8844-40a6-ab37-e5b89019af0a"
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("RequeueASR", mock.Anything, workspaceapp.Personal(userID), jobUUID).Return(nil)
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+		c.SetParamNames("uuid")
+		c.SetParamValues(jobUUID)
+
+		if assert.NoError(t, audiofilesHandler.RequeueASR(c)) {
+			assert.Equal(t, http.StatusAccepted, c.Response().Status)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("RequeueASR", mock.Anything, workspaceapp.Personal(userID), jobUUID).Return(database.NewErrorNotFound(errors.New(jobUUID)))
+
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, "")
+		c.SetParamNames("uuid")
+		c.SetParamValues(jobUUID)
+
+		err := audiofilesHandler.RequeueASR(c)
+		assert.Error(t, err)
+		httpError := err.(*echo.HTTPError)
+		assert.Equal(t, http.StatusNotFound, httpError.Code)
+	})
+}
+
+func TestAudioFilesHandler_Recognize(t *testing.T) {
+
+	storedFile := &audiofilesapp.AudioFile{
+		FileID:   "efc4ec14fd3fae7710335da2df3e14e5d0f031ed8e252005e501acb55e9f37d4",
+		FileName: "testfile.wav",
+		UserID:   userID,
+	}
+
+	job := *storedFile
+	job.UUID = uuid.MustParse("2d53b244-8844-40a6-ab37-e5b89019af0a")
+	job.ASR = "yandexSpeachKit"
+
+	getContext := func(mockAudioFileStore *mocks.MockAudioFileStore, reqBody string) (echo.Context, *AudioFilesHandler) {
+		c, audiofilesHandler := getEchoContext(t, mockAudioFileStore, reqBody)
+		c.SetParamNames("id_file")
+		c.SetParamValues(storedFile.FileID)
+		return c, audiofilesHandler
+	}
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), storedFile.FileID).Return(storedFile, nil)
+		mockAudioFileStore.On("CreateASR", mock.Anything, job).Return(nil)
+
+		c, audiofilesHandler := getContext(mockAudioFileStore, `{"asr": "yandexSpeachKit"}`)
+
+		if assert.NoError(t, audiofilesHandler.Recognize(c)) {
+			assert.Equal(t, http.StatusAccepted, c.Response().Status)
+			mockAudioFileStore.AssertExpectations(t)
+		}
+	})
+
+	t.Run("Bad request", func(t *testing.T) {
+
+		c, audiofilesHandler := getContext(new(mocks.MockAudioFileStore), `{}`)
+
+		err := audiofilesHandler.Recognize(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Unprocessable entity", func(t *testing.T) {
+
+		c, audiofilesHandler := getContext(new(mocks.MockAudioFileStore), `{"asr": "3iTech"}`)
+
+		err := audiofilesHandler.Recognize(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), storedFile.FileID).Return((*audiofilesapp.AudioFile)(nil), database.NewErrorNotFound(errors.New(storedFile.FileID)))
+
+		c, audiofilesHandler := getContext(mockAudioFileStore, `{"asr": "yandexSpeachKit"}`)
+
+		err := audiofilesHandler.Recognize(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+
+		mockAudioFileStore := new(mocks.MockAudioFileStore)
+		mockAudioFileStore.On("GetFile", mock.Anything, workspaceapp.Personal(userID), storedFile.FileID).Return(storedFile, nil)
+		mockAudioFileStore.On("CreateASR", mock.Anything, job).Return(database.NewErrorConflict(errors.New("409")))
+
+		c, audiofilesHandler := getContext(mockAudioFileStore, `{"asr": "yandexSpeachKit"}`)
+
+		if assert.NoError(t, audiofilesHandler.Recognize(c)) {
+			assert.Equal(t, http.StatusConflict, c.Response().Status)
+		}
+	})
 }
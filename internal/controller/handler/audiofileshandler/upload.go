@@ -0,0 +1,232 @@
+package audiofileshandler
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/wav"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	MIMEAudioWAV = "audio/wav"
+
+	defaultMaxSize = 512 << 20
+	maxFieldSize   = 4 << 10
+	// запас на поля JSON-запроса кроме audio
+	maxJSONOverhead = 64 << 10
+)
+
+// UploadParams параметры загрузки в multipart и raw вариантах, где аудио передаётся не в JSON
+type UploadParams struct {
+	ASR      ASRList `validate:"required,min=1,dive,required"`
+	FileName string  `validate:"required"`
+	Language string
+}
+
+// setAudioFileMultipart читает части формы по порядку: поля asr, file_name и language должны идти до части audio,
+// которая сразу передаётся в хранилище без буферизации в памяти
+func (lh *AudioFilesHandler) setAudioFileMultipart(c echo.Context, scope workspaceapp.Scope) error {
+
+	lh.extendReadDeadline(c)
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, lh.maxSize())
+
+	reader, err := c.Request().MultipartReader()
+	if err != nil {
+		log.Errorf("error in read multipart audio file request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	params := new(UploadParams)
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return echo.NewHTTPError(http.StatusBadRequest, "the audio part is missing")
+		}
+
+		if err != nil {
+			return lh.uploadError(err)
+		}
+
+		switch part.FormName() {
+		case "audio":
+			if params.FileName == "" {
+				params.FileName = part.FileName()
+			}
+
+			asrNames, err := lh.validateUpload(c, params)
+			if err != nil {
+				return err
+			}
+
+			newAudioFile := audiofilesapp.AudioFile{FileName: params.FileName, UserID: scope.UserID, WorkspaceID: scope.WorkspaceID, Language: params.Language}
+
+			return lh.upload(c, newAudioFile, asrNames, func(ctx context.Context) (string, error) {
+				return lh.AudioFilesApp.CreateFromReader(ctx, newAudioFile, asrNames, part)
+			})
+
+		case "asr", "file_name", "language":
+			value, err := io.ReadAll(io.LimitReader(part, maxFieldSize))
+			if err != nil {
+				return lh.uploadError(err)
+			}
+
+			switch part.FormName() {
+			case "asr":
+				params.ASR = append(params.ASR, string(value))
+			case "file_name":
+				params.FileName = string(value)
+			case "language":
+				params.Language = string(value)
+			}
+		}
+	}
+}
+
+// setAudioFileRaw принимает wav-файл телом запроса, остальные параметры передаются в query string
+func (lh *AudioFilesHandler) setAudioFileRaw(c echo.Context, scope workspaceapp.Scope) error {
+
+	params := &UploadParams{
+		ASR:      c.QueryParams()["asr"],
+		FileName: c.QueryParam("file_name"),
+		Language: c.QueryParam("language"),
+	}
+
+	asrNames, err := lh.validateUpload(c, params)
+	if err != nil {
+		return err
+	}
+
+	if c.Request().ContentLength > lh.maxSize() {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, lh.tooLargeMessage())
+	}
+
+	lh.extendReadDeadline(c)
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, lh.maxSize())
+
+	newAudioFile := audiofilesapp.AudioFile{FileName: params.FileName, UserID: scope.UserID, WorkspaceID: scope.WorkspaceID, Language: params.Language}
+
+	return lh.upload(c, newAudioFile, asrNames, func(ctx context.Context) (string, error) {
+		return lh.AudioFilesApp.CreateFromReader(ctx, newAudioFile, asrNames, body)
+	})
+}
+
+func (lh *AudioFilesHandler) validateUpload(c echo.Context, params *UploadParams) ([]string, error) {
+
+	if err := c.Validate(params); err != nil {
+		log.Errorf("error in validate audio file request. error: %v", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return lh.resolveASR(params.ASR)
+}
+
+func (lh *AudioFilesHandler) resolveASR(list ASRList) ([]string, error) {
+
+	asrNames := list.resolve(lh.ASRRegistry.Names())
+	for _, name := range asrNames {
+		if _, ok := lh.ASRRegistry.GetService(name); !ok {
+			return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, "")
+		}
+	}
+
+	return asrNames, nil
+}
+
+// upload сохраняет файл и ставит его в очередь на распознавание каждым из ASR
+func (lh *AudioFilesHandler) upload(c echo.Context, newAudioFile audiofilesapp.AudioFile, asrNames []string,
+	create func(ctx context.Context) (string, error)) error {
+
+	ctx := c.Request().Context()
+
+	var jobs []audiofilesapp.AudioFile
+
+	err := func() error {
+
+		var err error
+
+		newAudioFile.FileID, err = create(ctx)
+		if err != nil {
+			return err
+		}
+
+		jobs, err = lh.AudioFilesApp.EnqueueAll(ctx, newAudioFile, asrNames)
+
+		return err
+	}()
+
+	if err != nil {
+		return lh.uploadError(err)
+	}
+
+	response := UploadResponse{FileID: newAudioFile.FileID, Jobs: make([]Job, 0, len(jobs))}
+	for _, job := range jobs {
+		response.Jobs = append(response.Jobs, Job{UUID: job.UUID.String(), ASR: job.ASR})
+	}
+
+	return c.JSON(http.StatusAccepted, response)
+}
+
+func (lh *AudioFilesHandler) uploadError(err error) error {
+
+	log.Errorf("error: %v", err)
+
+	var errTooLarge *http.MaxBytesError
+	if errors.As(err, &errTooLarge) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, lh.tooLargeMessage())
+	}
+
+	if errors.Is(err, wav.ErrInvalid) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if errors.Is(err, audiofilesapp.ErrQuotaExceeded) {
+		return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
+
+// maxSize лимит размера wav-файла в байтах, MaxSize в конфиге задаётся в мегабайтах
+func (lh *AudioFilesHandler) maxSize() int64 {
+
+	if lh.cfg.MaxSize <= 0 {
+		return defaultMaxSize
+	}
+
+	return lh.cfg.MaxSize << 20
+}
+
+// maxJSONSize лимит тела JSON-запроса: wav-файл предельного размера в base64 и остальные поля
+func (lh *AudioFilesHandler) maxJSONSize() int64 {
+	return int64(base64.StdEncoding.EncodedLen(int(lh.maxSize()))) + maxJSONOverhead
+}
+
+func (lh *AudioFilesHandler) tooLargeMessage() string {
+	return fmt.Sprintf("the audio file exceeds %d bytes", lh.maxSize())
+}
+
+// extendReadDeadline продлевает ReadTimeout сервера для потоковой загрузки, иначе длинная запись не успеет дойти
+func (lh *AudioFilesHandler) extendReadDeadline(c echo.Context) {
+
+	if lh.cfg.ReadTimeout == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(time.Duration(lh.cfg.ReadTimeout) * time.Second)
+
+	if err := http.NewResponseController(c.Response()).SetReadDeadline(deadline); err != nil {
+		log.Warnf("error in extend read deadline for upload. error: %v", err)
+	}
+}
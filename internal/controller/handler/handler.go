@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/RecoBattle/internal/app/userapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/labstack/echo/v4"
 )
 
@@ -12,9 +13,20 @@ type Handler interface {
 	RegisterHandler(*echo.Echo, *echo.Group, *echo.Group)
 }
 
+// PrivateMiddleware реализуют хендлеры, которым нужен middleware для всей группы /api_private.
+// Он выполняется после аутентификации, когда пользователь уже известен
+type PrivateMiddleware interface {
+	PrivateMiddleware() echo.MiddlewareFunc
+}
+
+// AdminHandler реализуют хендлеры, которым нужны маршруты в группе /api_admin
+type AdminHandler interface {
+	RegisterAdminHandler(*echo.Group)
+}
+
 func GetUserID(c echo.Context) (string, error) {
 
-	userID := c.Get("user").(string)
+	userID, _ := c.Get("user").(string)
 
 	if userID == "" {
 		return "", fmt.Errorf("no user id")
@@ -23,6 +35,21 @@ func GetUserID(c echo.Context) (string, error) {
 	return userID, nil
 }
 
+// GetScope возвращает пространство запроса: рабочее пространство, членство в котором проверено middleware, или личное пространство пользователя
+func GetScope(c echo.Context) (workspaceapp.Scope, error) {
+
+	userID, err := GetUserID(c)
+	if err != nil {
+		return workspaceapp.Scope{}, err
+	}
+
+	if scope, ok := c.Get("scope").(workspaceapp.Scope); ok && scope.UserID == userID {
+		return scope, nil
+	}
+
+	return workspaceapp.Personal(userID), nil
+}
+
 func SendResponceToken(c echo.Context, response *userapp.LoginResponse) {
 
 	c.Response().Header().Set("Authorization", "Bearer "+response.AccessToken)
@@ -31,6 +58,38 @@ func SendResponceToken(c echo.Context, response *userapp.LoginResponse) {
 	writeRefreshTokenCookie(c, response.RefreshToken)
 }
 
+// GetRole возвращает роль пользователя из access-токена
+func GetRole(c echo.Context) string {
+
+	role, _ := c.Get("role").(string)
+
+	return role
+}
+
+// GetTokenFamily возвращает семейство токенов сессии из access-токена
+func GetTokenFamily(c echo.Context) string {
+
+	familyID, _ := c.Get("tokenFamily").(string)
+
+	return familyID
+}
+
+func ClearTokenCookies(c echo.Context) {
+
+	for _, name := range []string{"access_token", "refresh_token"} {
+
+		cookie := new(http.Cookie)
+
+		cookie.Name = name
+		cookie.HttpOnly = true
+		cookie.SameSite = 3
+		cookie.Path = "/"
+		cookie.MaxAge = -1
+
+		c.SetCookie(cookie)
+	}
+}
+
 func writeAccessTokenCookie(c echo.Context, accessToken string) {
 
 	cookie := new(http.Cookie)
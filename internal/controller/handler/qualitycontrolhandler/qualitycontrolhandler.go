@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/RecoBattle/internal/app/qualitycontrolapp"
+	"github.com/RecoBattle/internal/controller/handler"
 	"github.com/RecoBattle/internal/database"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
@@ -27,7 +28,9 @@ func NewQCHandler(qcApp *qualitycontrolapp.QualityControls) *QCHandler {
 func (lh *QCHandler) RegisterHandler(_ *echo.Echo, _, privateGroup *echo.Group) {
 
 	privateGroup.POST("/qualitycontrol/ideal", lh.SetIdealText)
+	privateGroup.GET("/qualitycontrol/report", lh.Report)
 	privateGroup.GET("/qualitycontrol/:id_file", lh.QualityControl)
+	privateGroup.GET("/qualitycontrol/:id_file/diff", lh.Diff)
 }
 
 // SetIdealText
@@ -38,6 +41,7 @@ func (lh *QCHandler) RegisterHandler(_ *echo.Echo, _, privateGroup *echo.Group)
 //	@Success      200 {string} wav file has already been uploaded by this user
 //	@Failure      400 {string} invalid request format
 //	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} the file was not found
 //	@Failure      409 {string} invalid ASR format or audio file type
 //	@Failure      500 {string} internal server error
 //	@Router       /api_private/qualitycontrol/ideal [post]
@@ -48,8 +52,13 @@ func (lh *QCHandler) SetIdealText(c echo.Context) error {
 	ca := make(chan bool)
 	errc := make(chan error)
 
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
 	idealText := new(RequestData)
-	err := c.Bind(idealText)
+	err = c.Bind(idealText)
 	if err != nil {
 		log.Errorf("error in bind ideal text request. error: %v", err)
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
@@ -62,7 +71,7 @@ func (lh *QCHandler) SetIdealText(c echo.Context) error {
 
 	go func() {
 
-		err = lh.QCApp.Create(c.Request().Context(), qualitycontrolapp.IdealText{FileID: idealText.FileID, ChannelTag: idealText.ChannelTag, Text: idealText.Text})
+		err = lh.QCApp.Create(c.Request().Context(), scope, qualitycontrolapp.IdealText{FileID: idealText.FileID, ChannelTag: idealText.ChannelTag, Text: idealText.Text})
 
 		if err != nil {
 			errc <- err
@@ -77,6 +86,10 @@ func (lh *QCHandler) SetIdealText(c echo.Context) error {
 		return c.String(http.StatusOK, "OK")
 	case err := <-errc:
 		log.Errorf("error: %v", err)
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
 		var errConflict *database.ConflictError
 		if errors.As(err, &errConflict) {
 			return c.String(http.StatusConflict, "")
@@ -94,6 +107,7 @@ func (lh *QCHandler) SetIdealText(c echo.Context) error {
 //	@Success      200 {object} wav file has already been uploaded by this user
 //	@Failure      204 {string} no data
 //	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} the file was not found
 //	@Failure      500 {string} internal server error
 //	@Router       /api_private/qualitycontrol/:id_file [get]
 //
@@ -103,11 +117,16 @@ func (lh *QCHandler) QualityControl(c echo.Context) error {
 	ca := make(chan []qualitycontrolapp.QualityControl)
 	errc := make(chan error)
 
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
 	fileID := c.Param("id_file")
 
 	go func() {
 
-		outputData, err := lh.QCApp.QualityControl(c.Request().Context(), fileID)
+		outputData, err := lh.QCApp.QualityControl(c.Request().Context(), scope, fileID)
 
 		if err != nil {
 			errc <- err
@@ -117,6 +136,114 @@ func (lh *QCHandler) QualityControl(c echo.Context) error {
 		ca <- *outputData
 	}()
 
+	select {
+	case result := <-ca:
+		if len(result) == 0 {
+			return echo.NewHTTPError(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// Diff
+//
+//	@Summary      Diff
+//	@Description  word-level alignment of the ideal text against each ASR result
+//	@Success      200 {object} array of aligned tokens for each ASR
+//	@Failure      204 {string} no data
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} the file was not found
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/qualitycontrol/:id_file/diff [get]
+//
+//	@Security JWT Token
+func (lh *QCHandler) Diff(c echo.Context) error {
+
+	ca := make(chan []qualitycontrolapp.Diff)
+	errc := make(chan error)
+
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	fileID := c.Param("id_file")
+
+	go func() {
+
+		outputData, err := lh.QCApp.Diff(c.Request().Context(), scope, fileID)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- *outputData
+	}()
+
+	select {
+	case result := <-ca:
+		if len(result) == 0 {
+			return echo.NewHTTPError(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// Report
+//
+//	@Summary      Report
+//	@Description  mean WER of each ASR against the total cost of recognizing the same files; files without an ideal text are skipped
+//	@Param        id_file query string false "files of the set, repeat the parameter for several files; all files with an ideal text by default"
+//	@Success      200 {object} array of reports for each ASR
+//	@Failure      204 {string} no data
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/qualitycontrol/report [get]
+//
+//	@Security JWT Token
+func (lh *QCHandler) Report(c echo.Context) error {
+
+	ca := make(chan []qualitycontrolapp.ASRReport)
+	errc := make(chan error)
+
+	scope, err := handler.GetScope(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	fileIDs := c.QueryParams()["id_file"]
+
+	go func() {
+
+		outputData, err := lh.QCApp.Report(c.Request().Context(), scope, fileIDs)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- outputData
+	}()
+
 	select {
 	case result := <-ca:
 		if len(result) == 0 {
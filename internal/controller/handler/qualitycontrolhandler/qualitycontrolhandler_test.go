@@ -11,6 +11,7 @@ import (
 	"github.com/RecoBattle/cmd/config"
 	"github.com/RecoBattle/internal/app/qualitycontrolapp"
 	"github.com/RecoBattle/internal/app/userapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/RecoBattle/internal/controller/handler"
 	"github.com/RecoBattle/internal/controller/router"
 	"github.com/RecoBattle/internal/database"
@@ -25,6 +26,7 @@ const ConfigASR = "../../../../cmd/config/config.toml"
 const PathTestFile = "../../../../testfile/test.wav"
 const userID = "2d53b244-8844-40a6-ab37-e5b89019af0a"
 const fileID = "1d35b422-7755-50a7-ab73-e4b98091af1a"
+const otherUserID = "5f0c7e3a-1b2d-4c5e-8f9a-0b1c2d3e4f5a"
 
 func getEchoContext(mockQCStore *mocks.MockQualityControlStore, reqBody string) (echo.Context, *QCHandler) {
 
@@ -41,7 +43,7 @@ func getEchoContext(mockQCStore *mocks.MockQualityControlStore, reqBody string)
 
 	userApp := userapp.NewUser(mockUserStore, cnf.ApiServer)
 
-	qcApp := qualitycontrolapp.NewQualityControl(mockQCStore)
+	qcApp := qualitycontrolapp.NewQualityControl(mockQCStore, nil)
 	qcHandler := NewQCHandler(qcApp)
 	registeredHandlers = append(registeredHandlers, qcHandler)
 
@@ -73,6 +75,7 @@ func TestQCHandler_SetIdealText(t *testing.T) {
 	}
 
 	mockQCStore := new(mocks.MockQualityControlStore)
+	mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
 	mockQCStore.On("Create", mock.Anything, qualityControl).Return(nil)
 	reqBody := `{"id_file": "", "ChannelTag": "1", "Text":"Hi"}`
 	c, qcHandler := getEchoContext(mockQCStore, reqBody)
@@ -99,6 +102,7 @@ func TestQCHandler_SetIdealText(t *testing.T) {
 	t.Run("Conflict", func(t *testing.T) {
 
 		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
 		mockQCStore.On("Create", mock.Anything, qualityControl).Return(database.NewErrorConflict(errors.New("409")))
 		reqBody = `{"id_file": "` + fileID + `", "ChannelTag": "1", "Text":"Hi"}`
 		c, qcHandler = getEchoContext(mockQCStore, reqBody)
@@ -107,6 +111,32 @@ func TestQCHandler_SetIdealText(t *testing.T) {
 			assert.Equal(t, http.StatusConflict, c.Response().Status)
 		}
 	})
+
+	t.Run("Another user's file", func(t *testing.T) {
+
+		// файл загружен userID в личное пространство
+		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
+		mockQCStore.On("Create", mock.Anything, qualityControl).Return(nil)
+		reqBody = `{"id_file": "` + fileID + `", "ChannelTag": "1", "Text":"Hi"}`
+		c, qcHandler = getEchoContext(mockQCStore, reqBody)
+		c.Set("user", otherUserID)
+
+		err := qcHandler.SetIdealText(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		mockQCStore.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c, qcHandler = getEchoContext(new(mocks.MockQualityControlStore), reqBody)
+		c.Set("user", "")
+
+		err := qcHandler.SetIdealText(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
 }
 
 func TestQCHandler_QualityControl(t *testing.T) {
@@ -116,7 +146,8 @@ func TestQCHandler_QualityControl(t *testing.T) {
 	t.Run("No content", func(t *testing.T) {
 
 		mockQCStore := new(mocks.MockQualityControlStore)
-		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, "Hi", nil)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
+		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, map[string]string{"1": "Hi"}, nil)
 
 		c, qcHandler := getEchoContext(mockQCStore, "")
 
@@ -127,17 +158,19 @@ func TestQCHandler_QualityControl(t *testing.T) {
 	})
 
 	resASR := qualitycontrolapp.QualityControl{
-		ASR:       "yandexSpeachKit",
-		TestIdeal: "Hi",
-		TextASR:   "Hi",
-		Quality:   90,
+		ASR:        "yandexSpeachKit",
+		ChannelTag: "1",
+		TestIdeal:  "Hi",
+		TextASR:    "Hi",
+		Quality:    90,
 	}
 	data = append(data, resASR)
 
 	t.Run("Successful", func(t *testing.T) {
 
 		mockQCStore := new(mocks.MockQualityControlStore)
-		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, "Hi", nil)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
+		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, map[string]string{"1": "Hi"}, nil)
 
 		c, qcHandler := getEchoContext(mockQCStore, "")
 
@@ -147,4 +180,130 @@ func TestQCHandler_QualityControl(t *testing.T) {
 
 	})
 
+	t.Run("Another user's file", func(t *testing.T) {
+
+		// файл загружен userID в личное пространство
+		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
+		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, map[string]string{"1": "Hi"}, nil)
+
+		c, qcHandler := getEchoContext(mockQCStore, "")
+		c.Set("user", otherUserID)
+
+		err := qcHandler.QualityControl(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		mockQCStore.AssertNotCalled(t, "GetTextASRIdeal", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c, qcHandler := getEchoContext(new(mocks.MockQualityControlStore), "")
+		c.Set("user", "")
+
+		err := qcHandler.QualityControl(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestQCHandler_Diff(t *testing.T) {
+
+	var data []qualitycontrolapp.QualityControl
+
+	t.Run("No content", func(t *testing.T) {
+
+		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
+		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, map[string]string{"1": "Hi"}, nil)
+
+		c, qcHandler := getEchoContext(mockQCStore, "")
+
+		err := qcHandler.Diff(c)
+		assert.Error(t, err)
+		httpError := err.(*echo.HTTPError)
+		assert.Equal(t, http.StatusNoContent, httpError.Code)
+	})
+
+	data = append(data, qualitycontrolapp.QualityControl{ASR: "yandexSpeachKit", ChannelTag: "1", TextASR: "Hi, there"})
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
+		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, map[string]string{"1": "Hi"}, nil)
+
+		c, qcHandler := getEchoContext(mockQCStore, "")
+
+		if assert.NoError(t, qcHandler.Diff(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+			assert.JSONEq(t, `[{"asr":"yandexSpeachKit", "channelTag":"1",
+				"wer":{"rate":1,"hits":1,"substitutions":0,"insertions":1,"deletions":0,"reference":1},
+				"tokens":[{"op":"match","ideal":"hi","asr":"hi"},{"op":"insertion","asr":"there"}]}]`,
+				c.Response().Writer.(*httptest.ResponseRecorder).Body.String())
+		}
+	})
+
+	t.Run("Another user's file", func(t *testing.T) {
+
+		// файл загружен userID в личное пространство
+		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileOwner", mock.Anything, fileID).Return(userID, "", nil)
+		mockQCStore.On("GetTextASRIdeal", mock.Anything, fileID).Return(data, map[string]string{"1": "Hi"}, nil)
+
+		c, qcHandler := getEchoContext(mockQCStore, "")
+		c.Set("user", otherUserID)
+
+		err := qcHandler.Diff(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		mockQCStore.AssertNotCalled(t, "GetTextASRIdeal", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c, qcHandler := getEchoContext(new(mocks.MockQualityControlStore), "")
+		c.Set("user", "")
+
+		err := qcHandler.Diff(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestQCHandler_Report(t *testing.T) {
+
+	files := []qualitycontrolapp.FileTexts{{
+		FileID:     fileID,
+		Duration:   30,
+		IdealTexts: map[string]string{"1": "добрый день"},
+		Results:    []qualitycontrolapp.QualityControl{{ASR: "vosk", ChannelTag: "1", TextASR: "добрый"}},
+	}}
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileTexts", mock.Anything, workspaceapp.Personal(userID), []string{fileID, "other"}).Return(files, nil)
+
+		c, qcHandler := getEchoContext(mockQCStore, "")
+		c.SetRequest(httptest.NewRequest(http.MethodGet, "/api_private/qualitycontrol/report?id_file="+fileID+"&id_file=other", nil))
+
+		if assert.NoError(t, qcHandler.Report(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+			assert.Contains(t, c.Response().Writer.(*httptest.ResponseRecorder).Body.String(), `"mean_wer":0.5`)
+		}
+	})
+
+	t.Run("No files with ideal text", func(t *testing.T) {
+
+		mockQCStore := new(mocks.MockQualityControlStore)
+		mockQCStore.On("GetFileTexts", mock.Anything, workspaceapp.Personal(userID), []string(nil)).Return([]qualitycontrolapp.FileTexts(nil), nil)
+
+		c, qcHandler := getEchoContext(mockQCStore, "")
+		c.SetRequest(httptest.NewRequest(http.MethodGet, "/api_private/qualitycontrol/report", nil))
+
+		err := qcHandler.Report(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNoContent, err.(*echo.HTTPError).Code)
+	})
 }
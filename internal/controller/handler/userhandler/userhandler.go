@@ -20,14 +20,29 @@ type RegisterRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type APIKeyRequest struct {
+	Name   string   `json:"name" validate:"required"`
+	Scopes []string `json:"scopes" validate:"dive,oneof=read upload qualitycontrol"`
+}
+
 func NewUserHandler(userapp *userapp.Users) *UserHandler {
 	return &UserHandler{UserApp: userapp}
 }
 
-func (lh *UserHandler) RegisterHandler(_ *echo.Echo, publicGroup, _ *echo.Group) {
+func (lh *UserHandler) RegisterHandler(_ *echo.Echo, publicGroup, privateGroup *echo.Group) {
 
 	publicGroup.POST("/user/register", lh.Register)
 	publicGroup.POST("/user/login", lh.Login)
+	publicGroup.POST("/user/token/refresh", lh.RefreshToken)
+
+	privateGroup.POST("/user/logout", lh.Logout)
+	privateGroup.POST("/user/apikeys", lh.CreateAPIKey)
+	privateGroup.GET("/user/apikeys", lh.GetAPIKeys)
+	privateGroup.DELETE("/user/apikeys/:id", lh.RevokeAPIKey)
 
 }
 
@@ -93,6 +108,7 @@ func (lh *UserHandler) Register(c echo.Context) error {
 //	@Success      200 {object} RegisterResponse
 //	@Failure      400 {string} please check request struct
 //	@Failure      401 {string} invalid username/password pair
+//	@Failure      403 {string} the account is disabled
 //	@Failure      500 {string} internal server error
 //	@Router       /api_public/user/register [post]
 func (lh *UserHandler) Login(c echo.Context) error {
@@ -132,9 +148,262 @@ func (lh *UserHandler) Login(c echo.Context) error {
 		if err.Error() == "401" {
 			return c.String(http.StatusUnauthorized, "invalid username/password pair")
 		}
+		if errors.Is(err, userapp.ErrDisabled) {
+			return c.String(http.StatusForbidden, "the account is disabled")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+
+}
+
+// RefreshToken обмен refresh-токена на новую пару токенов
+//
+//	@Summary      RefreshToken
+//	@Description  rotate the refresh token passed in the body or in the refresh_token cookie
+//	@Param        json body RefreshRequest false "Refresh token"
+//	@Success      200 {object} LoginResponse
+//	@Failure      400 {string} please check request struct
+//	@Failure      401 {string} the refresh token is invalid, expired, revoked or already used
+//	@Failure      500 {string} internal server error
+//	@Router       /api_public/user/token/refresh [post]
+func (lh *UserHandler) RefreshToken(c echo.Context) error {
+
+	ca := make(chan *userapp.LoginResponse)
+	errc := make(chan error)
+
+	request := new(RefreshRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind refresh token request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if request.RefreshToken == "" {
+		if cookie, err := c.Cookie("refresh_token"); err == nil {
+			request.RefreshToken = cookie.Value
+		}
+	}
+
+	if request.RefreshToken == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "refresh_token is required")
+	}
+
+	go func() {
+
+		refreshResult, err := lh.UserApp.Refresh(c.Request().Context(), request.RefreshToken)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- refreshResult
+	}()
+
+	select {
+	case result := <-ca:
+		handler.SendResponceToken(c, result)
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		if errors.Is(err, userapp.ErrUnauthorized) {
+			return c.String(http.StatusUnauthorized, "invalid refresh token")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// Logout отзыв refresh-токенов текущей сессии
+//
+//	@Summary      Logout
+//	@Description  revoke the refresh tokens of the current session and clear the token cookies
+//	@Success      200 {string} OK
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/user/logout [post]
+//
+//	@Security JWT Token
+func (lh *UserHandler) Logout(c echo.Context) error {
+
+	ca := make(chan bool, 1)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	go func() {
+
+		if err := lh.UserApp.Logout(c.Request().Context(), userID, handler.GetTokenFamily(c)); err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- true
+	}()
+
+	select {
+	case <-ca:
+		handler.ClearTokenCookies(c)
+		return c.String(http.StatusOK, "OK")
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// CreateAPIKey создание долгоживущего API-ключа
+//
+//	@Summary      CreateAPIKey
+//	@Description  create an API key for the X-API-Key header; the key itself is returned only once
+//	@Param        json body APIKeyRequest true "Key name and optional scopes: read, upload, qualitycontrol"
+//	@Success      201 {object} CreatedAPIKey
+//	@Failure      400 {string} please check request struct
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/user/apikeys [post]
+//
+//	@Security JWT Token
+func (lh *UserHandler) CreateAPIKey(c echo.Context) error {
+
+	ca := make(chan *userapp.CreatedAPIKey)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	request := new(APIKeyRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind API key request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		log.Errorf("error in bind API key request. error: %v", err)
+		return err
+	}
+
+	go func() {
+
+		apiKey, err := lh.UserApp.CreateAPIKey(c.Request().Context(), userID, request.Name, request.Scopes)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- apiKey
+	}()
+
+	select {
+	case result := <-ca:
+		return c.JSON(http.StatusCreated, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// GetAPIKeys список действующих API-ключей пользователя
+//
+//	@Summary      GetAPIKeys
+//	@Description  list the user's API keys that are not revoked, without the keys themselves
+//	@Success      200 {object} array with API keys
+//	@Failure      204 {string} no data for an answer
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/user/apikeys [get]
+//
+//	@Security JWT Token
+func (lh *UserHandler) GetAPIKeys(c echo.Context) error {
+
+	ca := make(chan []userapp.APIKey)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	go func() {
+
+		apiKeys, err := lh.UserApp.GetAPIKeys(c.Request().Context(), userID)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- apiKeys
+	}()
+
+	select {
+	case result := <-ca:
+		if len(result) == 0 {
+			return echo.NewHTTPError(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	case <-c.Request().Context().Done():
 		return nil
 	}
+}
+
+// RevokeAPIKey отзыв API-ключа
+//
+//	@Summary      RevokeAPIKey
+//	@Description  revoke the user's API key; requests with it are rejected from now on
+//	@Success      200 {string} OK
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} no active key with this id among the user's keys
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/user/apikeys/:id [delete]
+//
+//	@Security JWT Token
+func (lh *UserHandler) RevokeAPIKey(c echo.Context) error {
+
+	ca := make(chan bool, 1)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	keyID := c.Param("id")
+
+	go func() {
+
+		if err := lh.UserApp.RevokeAPIKey(c.Request().Context(), userID, keyID); err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- true
+	}()
 
+	select {
+	case <-ca:
+		return c.String(http.StatusOK, "OK")
+	case err := <-errc:
+		var errNotFound *database.NotFoundError
+		if errors.As(err, &errNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+		}
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
 }
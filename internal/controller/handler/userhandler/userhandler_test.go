@@ -1,12 +1,15 @@
 package userhandler
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/RecoBattle/cmd/config"
 	"github.com/RecoBattle/internal/app/userapp"
@@ -14,6 +17,7 @@ import (
 	"github.com/RecoBattle/internal/controller/router"
 	"github.com/RecoBattle/internal/database"
 	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -61,8 +65,14 @@ func TestUserHandler_Register(t *testing.T) {
 		Password: "cff17119871bdcd21a5638b1134ec1bcc9be47e0ce3bcd9863a2a24a68c862b5",
 	}
 
+	// пароль хешируется argon2id со случайной солью, поэтому сравнивается только формат хеша
+	withArgon2Hash := mock.MatchedBy(func(u userapp.User) bool {
+		return u.UUID == user.UUID && u.Username == user.Username && strings.HasPrefix(u.Password, "$argon2id$v=19$m=65536,t=3,p=4$")
+	})
+
 	mockUserStore := new(mocks.MockUserStore)
-	mockUserStore.On("Create", mock.Anything, *user).Return(nil)
+	mockUserStore.On("Create", mock.Anything, withArgon2Hash).Return(nil)
+	mockUserStore.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
 
 	t.Run("Bad request", func(t *testing.T) {
 
@@ -89,7 +99,7 @@ func TestUserHandler_Register(t *testing.T) {
 	t.Run("Conflict", func(t *testing.T) {
 
 		mockUserStore := new(mocks.MockUserStore)
-		mockUserStore.On("Create", mock.Anything, *user).Return(database.NewErrorConflict(errors.New("409")))
+		mockUserStore.On("Create", mock.Anything, withArgon2Hash).Return(database.NewErrorConflict(errors.New("409")))
 
 		c, userHandler := getEchoContext(mockUserStore, reqBody)
 
@@ -125,10 +135,33 @@ func TestUserHandler_Login(t *testing.T) {
 
 	})
 
+	isArgon2Hash := mock.MatchedBy(func(hash string) bool { return strings.HasPrefix(hash, "$argon2id$") })
+
 	t.Run("Successful Login", func(t *testing.T) {
 
 		mockUserStore := new(mocks.MockUserStore)
 		mockUserStore.On("GetUser", mock.Anything, map[string]string{"login": "testuser"}).Return(user, nil)
+		mockUserStore.On("UpdatePassword", mock.Anything, user.UUID.String(), isArgon2Hash).Return(nil)
+		mockUserStore.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+
+		c, userHandler := getEchoContext(mockUserStore, reqBody)
+
+		if assert.NoError(t, userHandler.Login(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+
+		// старый HMAC-хеш заменён на argon2id
+		mockUserStore.AssertCalled(t, "UpdatePassword", mock.Anything, user.UUID.String(), isArgon2Hash)
+	})
+
+	t.Run("Login with argon2id hash", func(t *testing.T) {
+
+		argon2User := *user
+		argon2User.Password = "$argon2id$v=19$m=65536,t=3,p=4$c29tZXNhbHRzb21lc2FsdA$+gq+yINMHtAw3sHxbJKQZmzIAD/jxGz6bhFlfCgCRVs"
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("GetUser", mock.Anything, map[string]string{"login": "testuser"}).Return(&argon2User, nil)
+		mockUserStore.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
 
 		c, userHandler := getEchoContext(mockUserStore, reqBody)
 
@@ -136,6 +169,461 @@ func TestUserHandler_Login(t *testing.T) {
 			assert.Equal(t, http.StatusOK, c.Response().Status)
 		}
 
+		c, userHandler = getEchoContext(mockUserStore, `{"login": "testuser", "password": "wrongpassword"}`)
+
+		if assert.NoError(t, userHandler.Login(c)) {
+			assert.Equal(t, http.StatusUnauthorized, c.Response().Status)
+		}
+
+		mockUserStore.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Role in access token", func(t *testing.T) {
+
+		admin := *user
+		admin.Password = "$argon2id$v=19$m=65536,t=3,p=4$c29tZXNhbHRzb21lc2FsdA$+gq+yINMHtAw3sHxbJKQZmzIAD/jxGz6bhFlfCgCRVs"
+		admin.Role = userapp.RoleAdmin
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("GetUser", mock.Anything, map[string]string{"login": "testuser"}).Return(&admin, nil)
+		mockUserStore.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+
+		_, userHandler := getEchoContext(mockUserStore, "")
+
+		tokens, err := userHandler.UserApp.Login(context.Background(), userapp.User{Username: "testuser", Password: "testpassword"})
+		if assert.NoError(t, err) {
+			_, claims, err := userapp.ParseToken(tokens.AccessToken, userHandler.UserApp.Cfg.SecretKeyForAccessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, userapp.RoleAdmin, claims.Role)
+		}
+	})
+
+	t.Run("Disabled account", func(t *testing.T) {
+
+		disabled := *user
+		disabled.Password = "$argon2id$v=19$m=65536,t=3,p=4$c29tZXNhbHRzb21lc2FsdA$+gq+yINMHtAw3sHxbJKQZmzIAD/jxGz6bhFlfCgCRVs"
+		disabled.Disabled = true
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("GetUser", mock.Anything, map[string]string{"login": "testuser"}).Return(&disabled, nil)
+
+		c, userHandler := getEchoContext(mockUserStore, reqBody)
+
+		if assert.NoError(t, userHandler.Login(c)) {
+			assert.Equal(t, http.StatusForbidden, c.Response().Status)
+		}
+
+		mockUserStore.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+	})
+
+}
+
+func TestUserHandler_RefreshToken(t *testing.T) {
+
+	user := &userapp.User{
+		UUID:     uuid.MustParse(userID),
+		Username: "testuser",
+		Password: "cff17119871bdcd21a5638b1134ec1bcc9be47e0ce3bcd9863a2a24a68c862b5",
+	}
+
+	// login выдаёт первую пару токенов, в issued попадают все сохранённые refresh-токены
+	login := func(t *testing.T, mockUserStore *mocks.MockUserStore) (*userapp.LoginResponse, *[]userapp.RefreshToken) {
+
+		issued := new([]userapp.RefreshToken)
+
+		mockUserStore.On("GetUser", mock.Anything, map[string]string{"login": "testuser"}).Return(user, nil)
+		mockUserStore.On("GetUser", mock.Anything, map[string]string{"uuid": userID}).Return(user, nil)
+		mockUserStore.On("UpdatePassword", mock.Anything, userID, mock.Anything).Return(nil)
+		mockUserStore.On("CreateRefreshToken", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*issued = append(*issued, args.Get(1).(userapp.RefreshToken))
+		}).Return(nil)
+
+		_, userHandler := getEchoContext(mockUserStore, "")
+
+		tokens, err := userHandler.UserApp.Login(context.Background(), userapp.User{Username: "testuser", Password: "testpassword"})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+
+		return tokens, issued
+	}
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		tokens, issued := login(t, mockUserStore)
+		first := (*issued)[0]
+
+		mockUserStore.On("UseRefreshToken", mock.Anything, first.JTI).Return(&first, nil)
+
+		c, userHandler := getEchoContext(mockUserStore, `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+
+		if assert.NoError(t, userHandler.RefreshToken(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+
+			var response userapp.LoginResponse
+			assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+			assert.NotEqual(t, tokens.RefreshToken, response.RefreshToken)
+
+			if assert.Len(t, *issued, 2) {
+				assert.Equal(t, first.FamilyID, (*issued)[1].FamilyID)
+				assert.NotEqual(t, first.JTI, (*issued)[1].JTI)
+			}
+		}
+	})
+
+	t.Run("Refresh token in cookie", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		tokens, issued := login(t, mockUserStore)
+		first := (*issued)[0]
+
+		mockUserStore.On("UseRefreshToken", mock.Anything, first.JTI).Return(&first, nil)
+
+		c, userHandler := getEchoContext(mockUserStore, "")
+		c.Request().AddCookie(&http.Cookie{Name: "refresh_token", Value: tokens.RefreshToken})
+
+		if assert.NoError(t, userHandler.RefreshToken(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("Reused token revokes the family", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		tokens, issued := login(t, mockUserStore)
+		used := (*issued)[0]
+		used.Used = true
+
+		mockUserStore.On("UseRefreshToken", mock.Anything, used.JTI).Return(&used, nil)
+		mockUserStore.On("RevokeRefreshTokens", mock.Anything, userID, used.FamilyID).Return(nil)
+
+		c, userHandler := getEchoContext(mockUserStore, `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+
+		if assert.NoError(t, userHandler.RefreshToken(c)) {
+			assert.Equal(t, http.StatusUnauthorized, c.Response().Status)
+		}
+
+		mockUserStore.AssertCalled(t, "RevokeRefreshTokens", mock.Anything, userID, used.FamilyID)
+		assert.Len(t, *issued, 1)
+	})
+
+	t.Run("Just exchanged token", func(t *testing.T) {
+
+		// параллельный запрос предъявил ту же cookie сразу после обмена
+		mockUserStore := new(mocks.MockUserStore)
+		tokens, issued := login(t, mockUserStore)
+		used := (*issued)[0]
+		used.Used = true
+		used.UsedAt = time.Now().Add(-time.Second)
+
+		mockUserStore.On("UseRefreshToken", mock.Anything, used.JTI).Return(&used, nil)
+
+		c, userHandler := getEchoContext(mockUserStore, `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+
+		if assert.NoError(t, userHandler.RefreshToken(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+
+		mockUserStore.AssertNotCalled(t, "RevokeRefreshTokens", mock.Anything, mock.Anything, mock.Anything)
+		if assert.Len(t, *issued, 2) {
+			assert.Equal(t, used.FamilyID, (*issued)[1].FamilyID)
+		}
+	})
+
+	t.Run("Token reused after the grace period", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		tokens, issued := login(t, mockUserStore)
+		used := (*issued)[0]
+		used.Used = true
+		used.UsedAt = time.Now().Add(-time.Minute)
+
+		mockUserStore.On("UseRefreshToken", mock.Anything, used.JTI).Return(&used, nil)
+		mockUserStore.On("RevokeRefreshTokens", mock.Anything, userID, used.FamilyID).Return(nil)
+
+		c, userHandler := getEchoContext(mockUserStore, `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+
+		if assert.NoError(t, userHandler.RefreshToken(c)) {
+			assert.Equal(t, http.StatusUnauthorized, c.Response().Status)
+		}
+
+		mockUserStore.AssertCalled(t, "RevokeRefreshTokens", mock.Anything, userID, used.FamilyID)
+		assert.Len(t, *issued, 1)
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		tokens, issued := login(t, mockUserStore)
+		revoked := (*issued)[0]
+		revoked.Revoked = true
+
+		mockUserStore.On("UseRefreshToken", mock.Anything, revoked.JTI).Return(&revoked, nil)
+
+		c, userHandler := getEchoContext(mockUserStore, `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+
+		if assert.NoError(t, userHandler.RefreshToken(c)) {
+			assert.Equal(t, http.StatusUnauthorized, c.Response().Status)
+		}
+
+		mockUserStore.AssertNotCalled(t, "RevokeRefreshTokens", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		tokens, _ := login(t, mockUserStore)
+
+		for _, token := range []string{"not a jwt", tokens.AccessToken} {
+
+			c, userHandler := getEchoContext(mockUserStore, `{"refresh_token": "`+token+`"}`)
+
+			if assert.NoError(t, userHandler.RefreshToken(c)) {
+				assert.Equal(t, http.StatusUnauthorized, c.Response().Status)
+			}
+		}
+
+		mockUserStore.AssertNotCalled(t, "UseRefreshToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("No refresh token", func(t *testing.T) {
+
+		c, userHandler := getEchoContext(new(mocks.MockUserStore), `{}`)
+
+		err := userHandler.RefreshToken(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestUserHandler_Logout(t *testing.T) {
+
+	familyID := "8f1f5c4e-2a3b-4c5d-9e8f-7a6b5c4d3e2f"
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("RevokeRefreshTokens", mock.Anything, userID, familyID).Return(nil)
+
+		c, userHandler := getEchoContext(mockUserStore, "")
+		c.Set("user", userID)
+		c.Set("tokenFamily", familyID)
+
+		if assert.NoError(t, userHandler.Logout(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+
+			cookies := c.Response().Header().Values("Set-Cookie")
+			if assert.Len(t, cookies, 2) {
+				assert.Contains(t, cookies[0], "access_token=;")
+				assert.Contains(t, cookies[1], "refresh_token=;")
+				assert.Contains(t, cookies[1], "Max-Age=0")
+			}
+		}
+
+		mockUserStore.AssertCalled(t, "RevokeRefreshTokens", mock.Anything, userID, familyID)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c, userHandler := getEchoContext(new(mocks.MockUserStore), "")
+
+		err := userHandler.Logout(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestUserHandler_CreateAPIKey(t *testing.T) {
+
+	t.Run("Successful", func(t *testing.T) {
+
+		var stored userapp.APIKey
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("CreateAPIKey", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(userapp.APIKey)
+		}).Return(nil)
+
+		c, userHandler := getEchoContext(mockUserStore, `{"name": "nightly benchmark", "scopes": ["upload", "read", "read"]}`)
+		c.Set("user", userID)
+
+		if assert.NoError(t, userHandler.CreateAPIKey(c)) {
+			assert.Equal(t, http.StatusCreated, c.Response().Status)
+
+			var response userapp.CreatedAPIKey
+			assert.NoError(t, json.Unmarshal(c.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &response))
+
+			assert.True(t, strings.HasPrefix(response.Key, response.Prefix))
+			assert.Equal(t, []string{"read", "upload"}, response.Scopes)
+
+			// в БД попадает только хеш ключа
+			assert.Equal(t, userID, stored.UserID)
+			assert.NotEmpty(t, stored.Hash)
+			assert.NotContains(t, stored.Hash, response.Key)
+			assert.NotContains(t, c.Response().Writer.(*httptest.ResponseRecorder).Body.String(), stored.Hash)
+		}
+	})
+
+	t.Run("Unknown scope", func(t *testing.T) {
+
+		c, userHandler := getEchoContext(new(mocks.MockUserStore), `{"name": "ci", "scopes": ["admin"]}`)
+		c.Set("user", userID)
+
+		err := userHandler.CreateAPIKey(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+
+		c, userHandler := getEchoContext(new(mocks.MockUserStore), `{"name": "ci"}`)
+
+		err := userHandler.CreateAPIKey(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestUserHandler_GetAPIKeys(t *testing.T) {
+
+	apiKeys := []userapp.APIKey{{UUID: uuid.New(), UserID: userID, Name: "ci", Prefix: "rb_AbCdEfGh", Hash: "secret-hash", Scopes: []string{"read"}}}
+
+	mockUserStore := new(mocks.MockUserStore)
+	mockUserStore.On("GetAPIKeys", mock.Anything, userID).Return(apiKeys, nil)
+
+	c, userHandler := getEchoContext(mockUserStore, "")
+	c.Set("user", userID)
+
+	if assert.NoError(t, userHandler.GetAPIKeys(c)) {
+		assert.Equal(t, http.StatusOK, c.Response().Status)
+
+		body := c.Response().Writer.(*httptest.ResponseRecorder).Body.String()
+		assert.Contains(t, body, `"prefix":"rb_AbCdEfGh"`)
+		assert.NotContains(t, body, "secret-hash")
+	}
+}
+
+func TestUserHandler_RevokeAPIKey(t *testing.T) {
+
+	keyID := "0b6f1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d"
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("RevokeAPIKey", mock.Anything, userID, keyID).Return(nil)
+
+		c, userHandler := getEchoContext(mockUserStore, "")
+		c.Set("user", userID)
+		c.SetParamNames("id")
+		c.SetParamValues(keyID)
+
+		if assert.NoError(t, userHandler.RevokeAPIKey(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("Another user's key", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("RevokeAPIKey", mock.Anything, userID, keyID).Return(database.NewErrorNotFound(errors.New(keyID)))
+
+		c, userHandler := getEchoContext(mockUserStore, "")
+		c.Set("user", userID)
+		c.SetParamNames("id")
+		c.SetParamValues(keyID)
+
+		err := userHandler.RevokeAPIKey(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
 	})
+}
 
+func TestRouter_JWTAuth(t *testing.T) {
+
+	mockUserStore := new(mocks.MockUserStore)
+	mockUserStore.On("GetAPIKeys", mock.Anything, userID).Return([]userapp.APIKey{{UUID: uuid.New(), UserID: userID, Name: "ci"}}, nil)
+
+	c, userHandler := getEchoContext(mockUserStore, "")
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &userapp.JWTCustomClaims{
+		UserID: userID,
+		Role:   userapp.RoleUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+
+	accessToken, err := token.SignedString([]byte(userHandler.UserApp.Cfg.SecretKeyForAccessToken))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	serve := func(req *http.Request) int {
+
+		rec := httptest.NewRecorder()
+		c.Echo().ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// echo-jwt кладёт токен в контекст по ContextKey и не должен затирать id пользователя, выставленный ParseToken
+	req := httptest.NewRequest(http.MethodGet, "/api_private/user/apikeys", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	assert.Equal(t, http.StatusOK, serve(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/api_private/user/apikeys", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken})
+	assert.Equal(t, http.StatusOK, serve(req))
+
+	mockUserStore.AssertNumberOfCalls(t, "GetAPIKeys", 2)
+}
+
+func TestRouter_APIKeyAuth(t *testing.T) {
+
+	const key = "rb_0123456789abcdefghijklmnopqrstuvwxyzABCDE"
+
+	readOnly := &userapp.APIKey{UUID: uuid.New(), UserID: userID, Scopes: []string{userapp.ScopeRead}}
+
+	mockUserStore := new(mocks.MockUserStore)
+	mockUserStore.On("UseAPIKey", mock.Anything, mock.Anything).Return(readOnly, nil)
+
+	c, _ := getEchoContext(mockUserStore, "")
+
+	serve := func(method, path, apiKey string) int {
+
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(router.HeaderAPIKey, apiKey)
+		rec := httptest.NewRecorder()
+
+		c.Echo().ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// ключ принят, запрос прошёл мимо echo-jwt до несуществующего маршрута
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/api_private/asr/unknown", key))
+	// у ключа нет scope upload
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api_private/asr/audiofile", key))
+	// управление ключами доступно только с JWT
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api_private/user/apikeys", key))
+	// ключ без префикса даже не ищется в БД
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/api_private/asr/audiofiles", "not-a-key"))
+	mockUserStore.AssertNumberOfCalls(t, "UseAPIKey", 3)
+
+	t.Run("Revoked key", func(t *testing.T) {
+
+		mockUserStore := new(mocks.MockUserStore)
+		mockUserStore.On("UseAPIKey", mock.Anything, mock.Anything).Return((*userapp.APIKey)(nil), database.NewErrorNotFound(errors.New("key")))
+
+		c, _ := getEchoContext(mockUserStore, "")
+
+		req := httptest.NewRequest(http.MethodGet, "/api_private/asr/audiofiles", nil)
+		req.Header.Set(router.HeaderAPIKey, key)
+		rec := httptest.NewRecorder()
+
+		c.Echo().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
 }
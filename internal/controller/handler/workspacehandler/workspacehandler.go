@@ -0,0 +1,364 @@
+package workspacehandler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/controller/handler"
+	"github.com/RecoBattle/internal/database"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// WorkspaceParam параметр запроса, которым эндпоинты файлов, результатов и оценки качества переключаются на рабочее пространство
+const WorkspaceParam = "workspace"
+
+type WorkspaceHandler struct {
+	WorkspaceApp *workspaceapp.Workspaces
+}
+
+type WorkspaceRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type MemberRequest struct {
+	Login string `json:"login" validate:"required"`
+	Role  string `json:"role" validate:"required"`
+}
+
+func NewWorkspaceHandler(workspaceApp *workspaceapp.Workspaces) *WorkspaceHandler {
+	return &WorkspaceHandler{WorkspaceApp: workspaceApp}
+}
+
+func (lh *WorkspaceHandler) RegisterHandler(_ *echo.Echo, _, privateGroup *echo.Group) {
+
+	privateGroup.POST("/workspaces", lh.CreateWorkspace)
+	privateGroup.GET("/workspaces", lh.GetWorkspaces)
+	privateGroup.GET("/workspaces/:id/members", lh.GetMembers)
+	privateGroup.PUT("/workspaces/:id/members", lh.SetMember)
+	privateGroup.DELETE("/workspaces/:id/members/:user_id", lh.RemoveMember)
+
+}
+
+// PrivateMiddleware проверяет членство пользователя в рабочем пространстве из параметра workspace и кладёт его Scope в контекст.
+// Участник с ролью viewer может выполнять только GET-запросы
+func (lh *WorkspaceHandler) PrivateMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			workspaceID := c.QueryParam(WorkspaceParam)
+			if workspaceID == "" {
+				return next(c)
+			}
+
+			// без пользователя хендлер сам ответит 401
+			userID, err := handler.GetUserID(c)
+			if err != nil {
+				return next(c)
+			}
+
+			scope, err := lh.WorkspaceApp.Authorize(c.Request().Context(), userID, workspaceID)
+			if err != nil {
+				return workspaceError(err)
+			}
+
+			method := c.Request().Method
+			if !scope.CanWrite() && method != http.MethodGet && method != http.MethodHead {
+				return echo.NewHTTPError(http.StatusForbidden, "viewers cannot change the workspace")
+			}
+
+			c.Set("scope", scope)
+
+			return next(c)
+		}
+	}
+}
+
+// CreateWorkspace
+//
+//	@Summary      CreateWorkspace
+//	@Description  create a workspace, the creator becomes its owner
+//	@Param        json body WorkspaceRequest true "Workspace name"
+//	@Success      201 {object} Workspace
+//	@Failure      400 {string} please check request struct
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/workspaces [post]
+//
+//	@Security JWT Token
+func (lh *WorkspaceHandler) CreateWorkspace(c echo.Context) error {
+
+	ca := make(chan *workspaceapp.Workspace)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	request := new(WorkspaceRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind workspace request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		log.Errorf("error in bind workspace request. error: %v", err)
+		return err
+	}
+
+	go func() {
+
+		workspace, err := lh.WorkspaceApp.Create(c.Request().Context(), userID, request.Name)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- workspace
+	}()
+
+	select {
+	case result := <-ca:
+		return c.JSON(http.StatusCreated, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// GetWorkspaces
+//
+//	@Summary      GetWorkspaces
+//	@Description  list the workspaces the user is a member of, with the user's role in each
+//	@Success      200 {object} array with workspaces
+//	@Failure      204 {string} no data for an answer
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/workspaces [get]
+//
+//	@Security JWT Token
+func (lh *WorkspaceHandler) GetWorkspaces(c echo.Context) error {
+
+	ca := make(chan []workspaceapp.Workspace)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	go func() {
+
+		workspaces, err := lh.WorkspaceApp.GetWorkspaces(c.Request().Context(), userID)
+
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- workspaces
+	}()
+
+	select {
+	case result := <-ca:
+		if len(result) == 0 {
+			return echo.NewHTTPError(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		log.Errorf("error: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// GetMembers
+//
+//	@Summary      GetMembers
+//	@Description  list the members of a workspace the user belongs to
+//	@Success      200 {object} array with members
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      404 {string} the user is not a member of the workspace
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/workspaces/:id/members [get]
+//
+//	@Security JWT Token
+func (lh *WorkspaceHandler) GetMembers(c echo.Context) error {
+
+	ca := make(chan []workspaceapp.Member)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	workspaceID := c.Param("id")
+
+	go func() {
+
+		scope, err := lh.WorkspaceApp.Authorize(c.Request().Context(), userID, workspaceID)
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		members, err := lh.WorkspaceApp.GetMembers(c.Request().Context(), scope)
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- members
+	}()
+
+	select {
+	case result := <-ca:
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		return workspaceError(err)
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// SetMember
+//
+//	@Summary      SetMember
+//	@Description  add a user to the workspace by login or change the member's role; owners only
+//	@Param        json body MemberRequest true "Login and role: owner, editor or viewer"
+//	@Success      200 {object} Member
+//	@Failure      400 {string} unknown role or the last owner would be demoted
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an owner of the workspace
+//	@Failure      404 {string} no such workspace among the user's or no user with this login
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/workspaces/:id/members [put]
+//
+//	@Security JWT Token
+func (lh *WorkspaceHandler) SetMember(c echo.Context) error {
+
+	ca := make(chan *workspaceapp.Member)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	request := new(MemberRequest)
+	if err := c.Bind(request); err != nil {
+		log.Errorf("error in bind member request. error: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		log.Errorf("error in bind member request. error: %v", err)
+		return err
+	}
+
+	workspaceID := c.Param("id")
+
+	go func() {
+
+		scope, err := lh.WorkspaceApp.Authorize(c.Request().Context(), userID, workspaceID)
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		member, err := lh.WorkspaceApp.SetMember(c.Request().Context(), scope, request.Login, request.Role)
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- member
+	}()
+
+	select {
+	case result := <-ca:
+		return c.JSON(http.StatusOK, result)
+	case err := <-errc:
+		return workspaceError(err)
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+// RemoveMember
+//
+//	@Summary      RemoveMember
+//	@Description  remove a member from the workspace; owners remove anyone, other members can only leave
+//	@Success      200 {string} OK
+//	@Failure      400 {string} the last owner cannot leave
+//	@Failure      401 {string} the user is not authenticated
+//	@Failure      403 {string} the user is not an owner of the workspace
+//	@Failure      404 {string} no such workspace among the user's or no such member
+//	@Failure      500 {string} internal server error
+//	@Router       /api_private/workspaces/:id/members/:user_id [delete]
+//
+//	@Security JWT Token
+func (lh *WorkspaceHandler) RemoveMember(c echo.Context) error {
+
+	ca := make(chan bool, 1)
+	errc := make(chan error)
+
+	userID, err := handler.GetUserID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	workspaceID := c.Param("id")
+	memberID := c.Param("user_id")
+
+	go func() {
+
+		scope, err := lh.WorkspaceApp.Authorize(c.Request().Context(), userID, workspaceID)
+		if err != nil {
+			errc <- err
+			return
+		}
+
+		if err := lh.WorkspaceApp.RemoveMember(c.Request().Context(), scope, memberID); err != nil {
+			errc <- err
+			return
+		}
+
+		ca <- true
+	}()
+
+	select {
+	case <-ca:
+		return c.String(http.StatusOK, "OK")
+	case err := <-errc:
+		return workspaceError(err)
+	case <-c.Request().Context().Done():
+		return nil
+	}
+}
+
+func workspaceError(err error) error {
+
+	switch {
+	case errors.Is(err, workspaceapp.ErrForbidden):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, workspaceapp.ErrInvalidRole), errors.Is(err, workspaceapp.ErrLastOwner):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var errNotFound *database.NotFoundError
+	if errors.As(err, &errNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "workspace or member not found")
+	}
+
+	log.Errorf("error: %v", err)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,271 @@
+package workspacehandler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/RecoBattle/internal/app/userapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/controller/handler"
+	"github.com/RecoBattle/internal/controller/router"
+	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const ConfigASR = "../../../../cmd/config/config.toml"
+const userID = "2d53b244-8844-40a6-ab37-e5b89019af0a"
+const otherUserID = "5f0c7e3a-1b2d-4c5e-8f9a-0b1c2d3e4f5a"
+const workspaceID = "9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+
+func getEchoContext(mockWorkspaceStore *mocks.MockWorkspaceStore, method, target, reqBody string) (echo.Context, *WorkspaceHandler) {
+
+	var registeredHandlers []handler.Handler
+
+	cfg := config.NewConfig()
+
+	cnf, err := cfg.GetConfig(ConfigASR)
+	if err != nil {
+		log.Fatalf("cnf is not set. Error: %v", err)
+	}
+
+	userApp := userapp.NewUser(new(mocks.MockUserStore), cnf.ApiServer)
+
+	workspaceHandler := NewWorkspaceHandler(workspaceapp.NewWorkspace(mockWorkspaceStore))
+	registeredHandlers = append(registeredHandlers, workspaceHandler)
+
+	e := router.NewRouter(cnf.ApiServer, registeredHandlers, userApp).Echo
+
+	req := httptest.NewRequest(method, target, strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	c.Set("user", userID)
+
+	return c, workspaceHandler
+}
+
+func TestWorkspaceHandler_CreateWorkspace(t *testing.T) {
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("CreateWorkspace", mock.Anything, mock.MatchedBy(func(w workspaceapp.Workspace) bool {
+			return w.Name == "call center" && w.CreatedBy == userID && w.Role == workspaceapp.RoleOwner
+		})).Return(nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPost, "/", `{"name": "call center"}`)
+
+		if assert.NoError(t, workspaceHandler.CreateWorkspace(c)) {
+			assert.Equal(t, http.StatusCreated, c.Response().Status)
+			assert.Contains(t, c.Response().Writer.(*httptest.ResponseRecorder).Body.String(), `"role":"owner"`)
+		}
+	})
+
+	t.Run("Without name", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPost, "/", `{}`)
+
+		err := workspaceHandler.CreateWorkspace(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		mockWorkspaceStore.AssertNotCalled(t, "CreateWorkspace", mock.Anything, mock.Anything)
+	})
+}
+
+func TestWorkspaceHandler_GetWorkspaces(t *testing.T) {
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetWorkspaces", mock.Anything, userID).Return([]workspaceapp.Workspace{{Name: "call center", Role: workspaceapp.RoleViewer}}, nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodGet, "/", "")
+
+		if assert.NoError(t, workspaceHandler.GetWorkspaces(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("No workspaces", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetWorkspaces", mock.Anything, userID).Return([]workspaceapp.Workspace(nil), nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodGet, "/", "")
+
+		err := workspaceHandler.GetWorkspaces(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNoContent, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestWorkspaceHandler_SetMember(t *testing.T) {
+
+	t.Run("Successful", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return(workspaceapp.RoleOwner, nil)
+		mockWorkspaceStore.On("GetMembers", mock.Anything, workspaceID).Return([]workspaceapp.Member{{UserID: userID, Login: "owner", Role: workspaceapp.RoleOwner}}, nil)
+		mockWorkspaceStore.On("SetMember", mock.Anything, workspaceID, "operator", workspaceapp.RoleViewer).
+			Return(&workspaceapp.Member{UserID: otherUserID, Login: "operator", Role: workspaceapp.RoleViewer}, nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPut, "/", `{"login": "operator", "role": "viewer"}`)
+		c.SetParamNames("id")
+		c.SetParamValues(workspaceID)
+
+		if assert.NoError(t, workspaceHandler.SetMember(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("Not an owner", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return(workspaceapp.RoleEditor, nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPut, "/", `{"login": "operator", "role": "owner"}`)
+		c.SetParamNames("id")
+		c.SetParamValues(workspaceID)
+
+		err := workspaceHandler.SetMember(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+		mockWorkspaceStore.AssertNotCalled(t, "SetMember", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Last owner", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return(workspaceapp.RoleOwner, nil)
+		mockWorkspaceStore.On("GetMembers", mock.Anything, workspaceID).Return([]workspaceapp.Member{{UserID: userID, Login: "owner", Role: workspaceapp.RoleOwner}}, nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPut, "/", `{"login": "owner", "role": "editor"}`)
+		c.SetParamNames("id")
+		c.SetParamValues(workspaceID)
+
+		err := workspaceHandler.SetMember(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		mockWorkspaceStore.AssertNotCalled(t, "SetMember", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not a member", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return("", database.NewErrorNotFound(errors.New(workspaceID)))
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPut, "/", `{"login": "operator", "role": "viewer"}`)
+		c.SetParamNames("id")
+		c.SetParamValues(workspaceID)
+
+		err := workspaceHandler.SetMember(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+}
+
+func TestWorkspaceHandler_RemoveMember(t *testing.T) {
+
+	t.Run("Leave", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return(workspaceapp.RoleViewer, nil)
+		mockWorkspaceStore.On("GetMembers", mock.Anything, workspaceID).Return([]workspaceapp.Member{
+			{UserID: otherUserID, Login: "owner", Role: workspaceapp.RoleOwner},
+			{UserID: userID, Login: "operator", Role: workspaceapp.RoleViewer},
+		}, nil)
+		mockWorkspaceStore.On("RemoveMember", mock.Anything, workspaceID, userID).Return(nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodDelete, "/", "")
+		c.SetParamNames("id", "user_id")
+		c.SetParamValues(workspaceID, userID)
+
+		if assert.NoError(t, workspaceHandler.RemoveMember(c)) {
+			assert.Equal(t, http.StatusOK, c.Response().Status)
+		}
+	})
+
+	t.Run("Remove other as viewer", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return(workspaceapp.RoleViewer, nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodDelete, "/", "")
+		c.SetParamNames("id", "user_id")
+		c.SetParamValues(workspaceID, otherUserID)
+
+		err := workspaceHandler.RemoveMember(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+		mockWorkspaceStore.AssertNotCalled(t, "RemoveMember", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWorkspaceHandler_PrivateMiddleware(t *testing.T) {
+
+	var scope workspaceapp.Scope
+
+	next := func(c echo.Context) error {
+		scope, _ = handler.GetScope(c)
+		return c.String(http.StatusOK, "OK")
+	}
+
+	t.Run("Personal", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodGet, "/api_private/asr/audiofiles", "")
+
+		if assert.NoError(t, workspaceHandler.PrivateMiddleware()(next)(c)) {
+			assert.Equal(t, workspaceapp.Personal(userID), scope)
+		}
+		mockWorkspaceStore.AssertNotCalled(t, "GetMemberRole", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Workspace", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return(workspaceapp.RoleEditor, nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPost, "/api_private/asr/audiofiles?workspace="+workspaceID, "")
+
+		if assert.NoError(t, workspaceHandler.PrivateMiddleware()(next)(c)) {
+			assert.Equal(t, workspaceapp.Scope{UserID: userID, WorkspaceID: workspaceID, Role: workspaceapp.RoleEditor}, scope)
+		}
+	})
+
+	t.Run("Viewer writes", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return(workspaceapp.RoleViewer, nil)
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodPost, "/api_private/asr/audiofiles?workspace="+workspaceID, "")
+
+		err := workspaceHandler.PrivateMiddleware()(next)(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	})
+
+	t.Run("Not a member", func(t *testing.T) {
+
+		mockWorkspaceStore := new(mocks.MockWorkspaceStore)
+		mockWorkspaceStore.On("GetMemberRole", mock.Anything, workspaceID, userID).Return("", database.NewErrorNotFound(errors.New(workspaceID)))
+
+		c, workspaceHandler := getEchoContext(mockWorkspaceStore, http.MethodGet, "/api_private/asr/audiofiles?workspace="+workspaceID, "")
+
+		err := workspaceHandler.PrivateMiddleware()(next)(c)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	})
+}
@@ -1,7 +1,10 @@
 package router
 
 import (
+	"errors"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/RecoBattle/cmd/config"
 	"github.com/RecoBattle/internal/app/userapp"
@@ -13,6 +16,9 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// HeaderAPIKey заголовок, в котором передаётся API-ключ вместо JWT
+const HeaderAPIKey = "X-API-Key"
+
 type Router struct {
 	Echo    *echo.Echo
 	UserApp *userapp.Users
@@ -46,6 +52,7 @@ func NewRouter(cfg config.ApiServer, handlers []handler.Handler, ua *userapp.Use
 
 	publicGroup := e.Group("/api_public")
 	privateGroup := e.Group("/api_private")
+	adminGroup := e.Group("/api_admin")
 
 	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -58,25 +65,45 @@ func NewRouter(cfg config.ApiServer, handlers []handler.Handler, ua *userapp.Use
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return &userapp.JWTCustomClaims{}
 		},
-		SigningKey:     []byte(cfg.SecretKeyForAccessToken),
+		SigningKey: []byte(cfg.SecretKeyForAccessToken),
+		// ParseToken сам кладёт в контекст "user", результат разбора нужно сохранить под другим ключом, иначе он затрёт id пользователя
+		ContextKey:     "token",
 		ParseTokenFunc: ParseToken,
 		ErrorHandler: func(c echo.Context, _ error) error {
-			return r.TokenRefresher(c, cfg)
+			return r.TokenRefresher(c)
 		},
 		ContinueOnIgnoredError: true,
 		TokenLookup:            "header:Authorization:Bearer ,cookie:access_token",
+		// запрос уже аутентифицирован API-ключом
+		Skipper: func(c echo.Context) bool {
+			_, ok := c.Get("apiKey").(*userapp.APIKey)
+			return ok
+		},
 	}
 
-	privateGroup.Use(echojwt.WithConfig(restrictedConfig))
+	privateGroup.Use(r.APIKeyAuth, echojwt.WithConfig(restrictedConfig))
+	// админский API доступен только по JWT: API-ключи в этой группе не принимаются
+	adminGroup.Use(echojwt.WithConfig(restrictedConfig), RequireRole(userapp.RoleAdmin))
 
-	for _, handler := range handlers {
-		handler.RegisterHandler(e, publicGroup, privateGroup)
+	// middleware группы применяется только к маршрутам, зарегистрированным после него
+	for _, h := range handlers {
+		if privateMiddleware, ok := h.(handler.PrivateMiddleware); ok {
+			privateGroup.Use(privateMiddleware.PrivateMiddleware())
+		}
+	}
+
+	for _, h := range handlers {
+		h.RegisterHandler(e, publicGroup, privateGroup)
+
+		if adminHandler, ok := h.(handler.AdminHandler); ok {
+			adminHandler.RegisterAdminHandler(adminGroup)
+		}
 	}
 
 	return r
 }
 
-func (rt *Router) TokenRefresher(c echo.Context, cfg config.ApiServer) error {
+func (rt *Router) TokenRefresher(c echo.Context) error {
 
 	cookie, err := c.Cookie("refresh_token")
 
@@ -84,10 +111,10 @@ func (rt *Router) TokenRefresher(c echo.Context, cfg config.ApiServer) error {
 		return c.String(http.StatusUnauthorized, "please check cookie")
 	}
 
-	tokenResponse, err := rt.UserApp.Token(c.Request().Context(), cookie.Value, cfg)
+	tokenResponse, err := rt.UserApp.Refresh(c.Request().Context(), cookie.Value)
 
 	if err != nil {
-		if err.Error() == "401" {
+		if errors.Is(err, userapp.ErrUnauthorized) {
 			return c.String(http.StatusUnauthorized, "")
 		}
 		return c.String(http.StatusInternalServerError, "")
@@ -98,6 +125,70 @@ func (rt *Router) TokenRefresher(c echo.Context, cfg config.ApiServer) error {
 	return nil
 }
 
+// APIKeyAuth аутентифицирует запрос по заголовку X-API-Key и проверяет scope ключа. Без заголовка запрос проверяется по JWT
+func (rt *Router) APIKeyAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+
+		key := c.Request().Header.Get(HeaderAPIKey)
+		if key == "" {
+			return next(c)
+		}
+
+		apiKey, err := rt.UserApp.AuthenticateAPIKey(c.Request().Context(), key)
+		if err != nil {
+			if errors.Is(err, userapp.ErrUnauthorized) {
+				return c.String(http.StatusUnauthorized, "invalid API key")
+			}
+			return c.String(http.StatusInternalServerError, "")
+		}
+
+		if !apiKey.Allows(requiredScope(c.Request().Method, c.Path())) {
+			return c.String(http.StatusForbidden, "the API key is not allowed to access this endpoint")
+		}
+
+		c.Set("user", apiKey.UserID)
+		c.Set("apiKey", apiKey)
+
+		return next(c)
+	}
+}
+
+// requiredScope scope API-ключа, нужный для маршрута. Пустая строка — маршрут доступен только по JWT:
+// ключами и сессией управляет сам пользователь, а не скрипт
+func requiredScope(method, path string) string {
+
+	switch {
+	case strings.HasPrefix(path, "/api_private/user/"):
+		return ""
+	case method == http.MethodGet || method == http.MethodHead:
+		return userapp.ScopeRead
+	case strings.HasPrefix(path, "/api_private/asr/"):
+		return userapp.ScopeUpload
+	case strings.HasPrefix(path, "/api_private/qualitycontrol/"):
+		return userapp.ScopeQualityControl
+	}
+
+	return ""
+}
+
+// RequireRole пропускает только запросы пользователей с одной из ролей, роль берётся из access-токена
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			if _, err := handler.GetUserID(c); err != nil {
+				return c.String(http.StatusUnauthorized, "")
+			}
+
+			if !slices.Contains(roles, handler.GetRole(c)) {
+				return c.String(http.StatusForbidden, "insufficient role")
+			}
+
+			return next(c)
+		}
+	}
+}
+
 func SecretKeyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		c.Set("secretKey", "your-secret-key-here")
@@ -116,6 +207,8 @@ func ParseToken(c echo.Context, auth string) (interface{}, error) {
 	}
 
 	c.Set("user", userClaims.UserID)
+	c.Set("tokenFamily", userClaims.FamilyID)
+	c.Set("role", userClaims.Role)
 
 	return true, nil
 }
@@ -0,0 +1,179 @@
+package admindb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/RecoBattle/internal/app/adminapp"
+	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/database"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ adminapp.AdminStore = &AdminStore{}
+
+const defaultJobsLimit = 100
+
+type AdminStore struct {
+	db *sql.DB
+}
+
+func NewAdminStore(db *sql.DB) *AdminStore {
+
+	return &AdminStore{db: db}
+}
+
+func (d *AdminStore) GetUsers(ctx context.Context) ([]adminapp.User, error) {
+
+	rows, err := d.db.QueryContext(ctx, "SELECT uuid, login, role, disabled_at IS NOT NULL FROM users ORDER BY login")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var users []adminapp.User
+
+	for rows.Next() {
+
+		var user adminapp.User
+		if err = rows.Scan(&user.UUID, &user.Login, &user.Role, &user.Disabled); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (d *AdminStore) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+
+	query := "UPDATE users SET disabled_at=NULL WHERE uuid=$1"
+	if disabled {
+		// повторное отключение не сдвигает время отключения
+		query = "UPDATE users SET disabled_at=COALESCE(disabled_at, now()) WHERE uuid=$1"
+	}
+
+	res, err := d.db.ExecContext(ctx, query, userID)
+
+	return checkUpdated(res, err, userID)
+}
+
+func (d *AdminStore) SetUserRole(ctx context.Context, userID, role string) error {
+
+	res, err := d.db.ExecContext(ctx, "UPDATE users SET role=$1 WHERE uuid=$2", role, userID)
+
+	return checkUpdated(res, err, userID)
+}
+
+func (d *AdminStore) GetJobs(ctx context.Context, filter adminapp.JobFilter) ([]adminapp.Job, error) {
+
+	condition := squirrel.Eq{}
+	if filter.UserID != "" {
+		condition["af.user_id"] = filter.UserID
+	}
+	if filter.ASR != "" {
+		condition["b.asr"] = filter.ASR
+	}
+	if filter.Status != "" {
+		condition["b.status"] = filter.Status
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = defaultJobsLimit
+	}
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	query, args, err := qb.Select("b.uuid", "b.asr", "b.status", "b.attempts", "b.last_error",
+		"af.file_id", "af.file_name", "af.uploaded_at", "af.user_id", "u.login").
+		From("asr b").
+		InnerJoin("audiofiles af ON af.file_id = b.file_id").
+		InnerJoin("users u ON u.uuid = af.user_id").
+		Where(condition).
+		OrderBy("b.created_at DESC").
+		Limit(limit).
+		Offset(filter.Offset).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var jobs []adminapp.Job
+
+	for rows.Next() {
+
+		var job adminapp.Job
+		if err = rows.Scan(&job.UUID, &job.ASR, &job.Status, &job.Attempts, &job.LastError,
+			&job.FileID, &job.FileName, &job.UploadedAt, &job.UserID, &job.Login); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// SetJobStatus при возврате в NEW сбрасывает счётчик попыток, чтобы задание прошло полный цикл повторов заново
+func (d *AdminStore) SetJobStatus(ctx context.Context, jobUUID, status string) error {
+
+	now := time.Now()
+
+	query := "UPDATE asr SET status=$1, last_error='status set by administrator', updated_at=$2 WHERE uuid=$3"
+	if status == audiofilesapp.StatusNEW {
+		query = "UPDATE asr SET status=$1, attempts=0, next_attempt_at=$2, updated_at=$2, queued_at=$2 WHERE uuid=$3"
+	}
+
+	res, err := d.db.ExecContext(ctx, query, status, now, jobUUID)
+
+	if err != nil {
+		// у файла уже есть активное задание этого ASR
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return database.NewErrorConflict(err)
+		}
+	}
+
+	return checkUpdated(res, err, jobUUID)
+}
+
+func checkUpdated(res sql.Result, err error, id string) error {
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return database.NewErrorNotFound(errors.New(id))
+	}
+
+	return nil
+}
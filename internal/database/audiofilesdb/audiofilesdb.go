@@ -3,12 +3,16 @@ package audiofilesdb
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/workspacedb"
+	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -26,7 +30,10 @@ func NewAudioFileStore(db *sql.DB) *AudioFileStore {
 
 func (d *AudioFileStore) CreateFile(ctx context.Context, audioFile audiofilesapp.AudioFile) error {
 
-	_, err := d.db.ExecContext(ctx, "INSERT INTO audiofiles (file_id, file_name, user_id, uploaded_at) VALUES($1,$2,$3,$4)", audioFile.FileID, audioFile.FileName, audioFile.UserID, time.Now())
+	_, err := d.db.ExecContext(ctx, `INSERT INTO audiofiles (file_id, file_name, user_id, workspace_id, uploaded_at, content_hash, storage_key, codec, sample_rate_hertz, bits_per_sample, channels, duration)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
+		audioFile.FileID, audioFile.FileName, audioFile.UserID, workspacedb.NullWorkspace(audioFile.WorkspaceID), time.Now(), audioFile.Hash, audioFile.StorageKey,
+		audioFile.Audio.Codec, audioFile.Audio.SampleRateHertz, audioFile.Audio.BitsPerSample, audioFile.Audio.Channels, audioFile.Audio.Duration)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -42,62 +49,357 @@ func (d *AudioFileStore) CreateFile(ctx context.Context, audioFile audiofilesapp
 
 func (d *AudioFileStore) CreateASR(ctx context.Context, audioFile audiofilesapp.AudioFile) error {
 
-	_, err := d.db.ExecContext(ctx, "INSERT INTO asr (uuid, file_id, asr, status) VALUES($1,$2,$3,$4)", audioFile.UUID.String(), audioFile.FileID, audioFile.ASR, audiofilesapp.StatusPROCESSING)
+	// длительность аудио для учёта расхода берётся из файла
+	res, err := d.db.ExecContext(ctx, `INSERT INTO asr (uuid, file_id, asr, status, language, user_id, audio_seconds, created_at, updated_at, queued_at)
+		SELECT $1, file_id, $3, $4, $5, $6, duration, $7, $7, $7 FROM audiofiles WHERE file_id=$2`,
+		audioFile.UUID.String(), audioFile.FileID, audioFile.ASR, audiofilesapp.StatusNEW, audioFile.Language, audioFile.UserID, time.Now())
 
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return database.NewErrorConflict(err)
+		}
+
+		return err
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if rows == 0 {
+		return database.NewErrorNotFound(errors.New(audioFile.FileID))
+	}
+
 	return nil
 }
 
-func (d *AudioFileStore) UpdateStatusASR(ctx context.Context, audioFileUUID, status string) error {
+// CreateASRs создаёт задания в одной транзакции, при ошибке не создаётся ни одно. Задание пропускается, если файл уже
+// стоит в очереди этого ASR или распознан им; возвращаются созданные задания
+func (d *AudioFileStore) CreateASRs(ctx context.Context, jobs []audiofilesapp.AudioFile) ([]audiofilesapp.AudioFile, error) {
 
-	tx, err := d.db.Begin()
+	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx, "UPDATE asr SET status=$1 WHERE uuid=$2", status, audioFileUUID)
+	now := time.Now()
+
+	created := make([]audiofilesapp.AudioFile, 0, len(jobs))
+
+	for _, job := range jobs {
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO asr (uuid, file_id, asr, status, language, user_id, audio_seconds, created_at, updated_at, queued_at)
+			SELECT $1, file_id, $3, $4, $5, $6, duration, $7, $7, $7 FROM audiofiles WHERE file_id=$2
+			ON CONFLICT DO NOTHING`,
+			job.UUID.String(), job.FileID, job.ASR, audiofilesapp.StatusNEW, job.Language, job.UserID, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if rows > 0 {
+			created = append(created, job)
+			continue
+		}
+
+		var exists bool
+		if err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM audiofiles WHERE file_id=$1)", job.FileID).Scan(&exists); err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, database.NewErrorNotFound(errors.New(job.FileID))
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+func (d *AudioFileStore) GetFile(ctx context.Context, scope workspaceapp.Scope, fileID string) (*audiofilesapp.AudioFile, error) {
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	query, args, err := qb.Select("file_id", "file_name", "user_id", "COALESCE(workspace_id, '')", "uploaded_at", "content_hash", "storage_key", "codec", "sample_rate_hertz", "bits_per_sample", "channels", "duration").
+		From("audiofiles").
+		Where(squirrel.Eq{"file_id": fileID}).
+		Where(workspacedb.ScopeCondition("", scope)).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var file audiofilesapp.AudioFile
+
+	err = d.db.QueryRowContext(ctx, query, args...).Scan(&file.FileID, &file.FileName, &file.UserID, &file.WorkspaceID, &file.UploadedAt, &file.Hash, &file.StorageKey,
+		&file.Audio.Codec, &file.Audio.SampleRateHertz, &file.Audio.BitsPerSample, &file.Audio.Channels, &file.Audio.Duration)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.NewErrorNotFound(errors.New(fileID))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
 
+// ClaimASR забирает задание из очереди и помечает его именем экземпляра сервиса и новым claim_id.
+// Результат попытки записывается только по claim_id, поэтому задание, которое администратор тем временем
+// перевёл в другой статус или вернул в очередь, не перезаписывается
+func (d *AudioFileStore) ClaimASR(ctx context.Context, instance string) (*audiofilesapp.AudioFile, error) {
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	query, args, err := qb.Select("a.uuid", "a.file_id", "a.asr", "a.language", "a.attempts", "f.file_name", "f.user_id", "f.uploaded_at", "f.content_hash", "f.storage_key",
+		"f.codec", "f.sample_rate_hertz", "f.bits_per_sample", "f.channels", "f.duration").
+		From("asr a").
+		InnerJoin("audiofiles f ON a.file_id = f.file_id").
+		Where(squirrel.Eq{"a.status": audiofilesapp.StatusNEW}).
+		Where(squirrel.LtOrEq{"a.next_attempt_at": time.Now()}).
+		OrderBy("a.next_attempt_at", "a.created_at").
+		Limit(1).
+		Suffix("FOR UPDATE OF a SKIP LOCKED").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var file audiofilesapp.AudioFile
+
+	err = tx.QueryRowContext(ctx, query, args...).
+		Scan(&file.UUID, &file.FileID, &file.ASR, &file.Language, &file.Attempts, &file.FileName, &file.UserID, &file.UploadedAt, &file.Hash, &file.StorageKey,
+			&file.Audio.Codec, &file.Audio.SampleRateHertz, &file.Audio.BitsPerSample, &file.Audio.Channels, &file.Audio.Duration)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	file.ClaimID = uuid.NewString()
+
+	// started_at — начало последней попытки, время обработки считается только по ней
+	_, err = tx.ExecContext(ctx, "UPDATE asr SET status=$1, attempts=attempts+1, updated_at=$2, started_at=$2, heartbeat_at=$2, finished_at=NULL, upstream_seconds=NULL, claimed_by=$3, claim_id=$4 WHERE uuid=$5",
+		audiofilesapp.StatusPROCESSING, time.Now(), instance, file.ClaimID, file.UUID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	file.Status = audiofilesapp.StatusPROCESSING
+	file.Attempts++
+
+	return &file, tx.Commit()
+}
+
+// ResumeASR возвращает в очередь задания, оставшиеся в PROCESSING у экземпляра instance. Задания с пустым claimed_by
+// забраны до появления колонки и тоже возвращаются. claim_id сбрасывается, чтобы прерванная попытка ничего не записала
+func (d *AudioFileStore) ResumeASR(ctx context.Context, instance string) (int64, error) {
+
+	res, err := d.db.ExecContext(ctx, "UPDATE asr SET status=$1, claim_id='', updated_at=$2 WHERE status=$3 AND claimed_by IN ($4, '')",
+		audiofilesapp.StatusNEW, time.Now(), audiofilesapp.StatusPROCESSING, instance)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// HeartbeatASR продлевает аренду задания попыткой claimID. Задание, которое попытке больше не принадлежит, — ConflictError
+func (d *AudioFileStore) HeartbeatASR(ctx context.Context, audioFileUUID, claimID string) error {
+
+	res, err := d.db.ExecContext(ctx, "UPDATE asr SET heartbeat_at=$1 WHERE uuid=$2 AND claim_id=$3 AND status=$4",
+		time.Now(), audioFileUUID, claimID, audiofilesapp.StatusPROCESSING)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	return claimed(res, audioFileUUID)
+}
+
+// ReleaseStaleASR возвращает в очередь задания любого экземпляра, аренда которых не продлевалась с staleBefore:
+// экземпляр упал и под тем же именем не вернулся. claim_id сбрасывается, поэтому зависшая попытка, если она
+// всё же жива, ничего не запишет
+func (d *AudioFileStore) ReleaseStaleASR(ctx context.Context, staleBefore time.Time) (int64, error) {
+
+	res, err := d.db.ExecContext(ctx, "UPDATE asr SET status=$1, claim_id='', updated_at=$2 WHERE status=$3 AND COALESCE(heartbeat_at, started_at, updated_at) < $4",
+		audiofilesapp.StatusNEW, time.Now(), audiofilesapp.StatusPROCESSING, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
 }
 
-func (d *AudioFileStore) CreateResultASR(ctx context.Context, resultASR audiofilesapp.ResultASR) error {
+// RetryASR возвращает задание в очередь до nextAttemptAt. Замеры неудачной попытки сохраняются до начала следующей
+func (d *AudioFileStore) RetryASR(ctx context.Context, audioFileUUID, claimID, lastError string, upstream time.Duration, nextAttemptAt time.Time) error {
 
-	_, err := d.db.ExecContext(ctx, "INSERT INTO result_asr (uuid, channel_tag, text, start_time, end_time) VALUES($1,$2,$3,$4,$5)",
-		resultASR.UUID.String(), resultASR.ChannelTag, resultASR.Text, resultASR.StartTime, resultASR.EndTime)
+	res, err := d.db.ExecContext(ctx, "UPDATE asr SET status=$1, last_error=$2, next_attempt_at=$3, updated_at=$4, finished_at=$4, upstream_seconds=$5 WHERE uuid=$6 AND claim_id=$7 AND status=$8",
+		audiofilesapp.StatusNEW, lastError, nextAttemptAt, time.Now(), upstreamSeconds(upstream), audioFileUUID, claimID, audiofilesapp.StatusPROCESSING)
 
 	if err != nil {
 		return err
 	}
 
+	return claimed(res, audioFileUUID)
+}
+
+func (d *AudioFileStore) FailASR(ctx context.Context, audioFileUUID, claimID, status, lastError string, upstream time.Duration) error {
+
+	res, err := d.db.ExecContext(ctx, "UPDATE asr SET status=$1, last_error=$2, updated_at=$3, finished_at=$3, upstream_seconds=$4 WHERE uuid=$5 AND claim_id=$6 AND status=$7",
+		status, lastError, time.Now(), upstreamSeconds(upstream), audioFileUUID, claimID, audiofilesapp.StatusPROCESSING)
+
+	if err != nil {
+		return err
+	}
+
+	return claimed(res, audioFileUUID)
+}
+
+// upstreamSeconds время ответа ASR для upstream_seconds. Попытка, не дошедшая до ASR, замера не имеет — NULL
+func upstreamSeconds(upstream time.Duration) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: upstream.Seconds(), Valid: upstream > 0}
+}
+
+// claimed проверяет, что попытка обновила задание. Ни одной строки — задание больше не принадлежит попытке: ConflictError
+func claimed(res sql.Result, audioFileUUID string) error {
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return database.NewErrorConflict(errors.New(audioFileUUID))
+	}
+
 	return nil
 }
 
-func (d *AudioFileStore) GetAudioFiles(ctx context.Context, userID string) (*[]audiofilesapp.AudioFile, error) {
+func (d *AudioFileStore) RequeueASR(ctx context.Context, scope workspaceapp.Scope, audioFileUUID string) error {
 
-	var rows *sql.Rows
+	now := time.Now()
 
 	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 
-	_, err := d.db.ExecContext(ctx, "CREATE TEMP TABLE temp_audiofiles AS SELECT file_id, file_name, uploaded_at FROM audiofiles WHERE user_id=$1;", userID)
+	files, filesArgs, err := squirrel.Select("file_id").From("audiofiles").Where(workspacedb.ScopeCondition("", scope)).ToSql()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	query, args, err := qb.Update("asr").
+		Set("status", audiofilesapp.StatusNEW).
+		Set("attempts", 0).
+		Set("next_attempt_at", now).
+		Set("updated_at", now).
+		Set("queued_at", now).
+		Where(squirrel.Eq{"uuid": audioFileUUID, "status": []string{audiofilesapp.StatusFAILED, audiofilesapp.StatusINVALID}}).
+		Where("file_id IN ("+files+")", filesArgs...).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	res, err := d.db.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return database.NewErrorConflict(err)
+		}
+
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return database.NewErrorNotFound(errors.New(audioFileUUID))
 	}
 
-	rows, err = qb.Select("a.file_id", "a.file_name", "a.uploaded_at", "b.uuid", "b.asr", "b.status").
-		From("temp_audiofiles a").
+	return nil
+}
+
+func (d *AudioFileStore) SaveResultASR(ctx context.Context, audioFileUUID, claimID string, resultASR []audiofilesapp.ResultASR, upstream time.Duration) error {
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE asr SET status=$1, updated_at=$2, finished_at=$2, upstream_seconds=$3 WHERE uuid=$4 AND claim_id=$5 AND status=$6",
+		audiofilesapp.StatusPROCESSED, time.Now(), upstream.Seconds(), audioFileUUID, claimID, audiofilesapp.StatusPROCESSING)
+	if err != nil {
+		return err
+	}
+
+	if err = claimed(res, audioFileUUID); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM result_asr WHERE uuid=$1", audioFileUUID); err != nil {
+		return err
+	}
+
+	for _, res := range resultASR {
+
+		words, err := json.Marshal(res.Words)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO result_asr (uuid, channel_tag, text, start_time, end_time, confidence, words) VALUES($1,$2,$3,$4,$5,$6,$7)",
+			audioFileUUID, res.ChannelTag, res.Text, res.StartTime, res.EndTime, res.Confidence, words)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (d *AudioFileStore) GetAudioFiles(ctx context.Context, scope workspaceapp.Scope) (*[]audiofilesapp.AudioFile, error) {
+
+	var rows *sql.Rows
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	rows, err := qb.Select("a.file_id", "a.file_name", "a.uploaded_at", "COALESCE(a.workspace_id, '')",
+		"a.codec", "a.sample_rate_hertz", "a.bits_per_sample", "a.channels", "a.duration",
+		"b.uuid", "COALESCE(b.asr, '')", "COALESCE(b.status, '')", "COALESCE(b.attempts, 0)", "COALESCE(b.last_error, '')",
+		"b.queued_at", "b.started_at", "b.finished_at", "b.upstream_seconds").
+		From("audiofiles a").
 		LeftJoin("asr b ON a.file_id = b.file_id").
+		Where(workspacedb.ScopeCondition("a.", scope)).
 		OrderBy("a.uploaded_at DESC").
 		RunWith(d.db).
-		Query()
+		QueryContext(ctx)
 
 	if err != nil {
 		return nil, err
@@ -113,25 +415,71 @@ func (d *AudioFileStore) GetAudioFiles(ctx context.Context, userID string) (*[]a
 
 	for rows.Next() {
 
-		var file audiofilesapp.AudioFile
-		if err = rows.Scan(&file.FileID, &file.FileName, &file.UploadedAt, &file.UUID, &file.ASR, &file.Status); err != nil {
+		var (
+			file                            audiofilesapp.AudioFile
+			queuedAt, startedAt, finishedAt sql.NullTime
+			upstream                        sql.NullFloat64
+		)
+
+		if err = rows.Scan(&file.FileID, &file.FileName, &file.UploadedAt, &file.WorkspaceID,
+			&file.Audio.Codec, &file.Audio.SampleRateHertz, &file.Audio.BitsPerSample, &file.Audio.Channels, &file.Audio.Duration, &file.UUID, &file.ASR, &file.Status, &file.Attempts, &file.LastError,
+			&queuedAt, &startedAt, &finishedAt, &upstream); err != nil {
 			return nil, err
 		}
+
+		if queuedAt.Valid {
+			file.Timings = &audiofilesapp.Timings{QueuedAt: queuedAt.Time}
+
+			if startedAt.Valid {
+				file.Timings.StartedAt = &startedAt.Time
+			}
+
+			if finishedAt.Valid {
+				file.Timings.FinishedAt = &finishedAt.Time
+			}
+
+			if upstream.Valid {
+				file.Timings.UpstreamSeconds = &upstream.Float64
+			}
+		}
+
 		files = append(files, file)
 	}
 
 	return &files, nil
 }
 
+// GetASR задание вместе с владельцем его файла: UserID и WorkspaceID — загрузивший файл и его рабочее пространство.
+// Принадлежность пространству пользователя проверяет приложение
+func (d *AudioFileStore) GetASR(ctx context.Context, uuid string) (*audiofilesapp.AudioFile, error) {
+
+	var file audiofilesapp.AudioFile
+
+	err := d.db.QueryRowContext(ctx, `SELECT a.uuid, a.file_id, a.asr, a.status, f.user_id, COALESCE(f.workspace_id, '')
+		FROM asr a INNER JOIN audiofiles f ON a.file_id = f.file_id WHERE a.uuid=$1`, uuid).
+		Scan(&file.UUID, &file.FileID, &file.ASR, &file.Status, &file.UserID, &file.WorkspaceID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.NewErrorNotFound(errors.New(uuid))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
 func (d *AudioFileStore) GetResultASR(ctx context.Context, uuid string) (*[]audiofilesapp.ResultASR, error) {
 
 	var rows *sql.Rows
 
 	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 
-	rows, err := qb.Select("channel_tag", "text", "start_time", "end_time").
+	rows, err := qb.Select("channel_tag", "text", "start_time", "end_time", "confidence", "words").
 		From("result_asr").
 		Where(squirrel.Eq{"uuid": uuid}).
+		OrderBy("start_time").
 		RunWith(d.db).
 		QueryContext(ctx)
 
@@ -150,7 +498,11 @@ func (d *AudioFileStore) GetResultASR(ctx context.Context, uuid string) (*[]audi
 	for rows.Next() {
 
 		var res audiofilesapp.ResultASR
-		if err = rows.Scan(&res.ChannelTag, &res.Text, &res.StartTime, &res.EndTime); err != nil {
+		var words []byte
+		if err = rows.Scan(&res.ChannelTag, &res.Text, &res.StartTime, &res.EndTime, &res.Confidence, &words); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(words, &res.Words); err != nil {
 			return nil, err
 		}
 		resASR = append(resASR, res)
@@ -158,3 +510,49 @@ func (d *AudioFileStore) GetResultASR(ctx context.Context, uuid string) (*[]audi
 
 	return &resASR, nil
 }
+
+// GetUsage расход аудио по каждому ASR по заданиям, поставленным в очередь начиная с since; пустой userID — по всем пользователям
+func (d *AudioFileStore) GetUsage(ctx context.Context, userID string, since time.Time) ([]audiofilesapp.Usage, error) {
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	builder := qb.Select("asr", "COUNT(*)", "COALESCE(SUM(audio_seconds), 0)").
+		From("asr").
+		Where(squirrel.GtOrEq{"created_at": since}).
+		GroupBy("asr").
+		OrderBy("asr")
+
+	if userID != "" {
+		builder = builder.Where(squirrel.Eq{"user_id": userID})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var usage []audiofilesapp.Usage
+
+	for rows.Next() {
+
+		var u audiofilesapp.Usage
+		if err = rows.Scan(&u.ASR, &u.Jobs, &u.Seconds); err != nil {
+			return nil, err
+		}
+
+		usage = append(usage, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
@@ -31,7 +31,7 @@ func NewDB(ps string) (*sql.DB, error) {
 	defer m.Close()
 
 	err = m.Up()
-	if errors.Is(err, migrate.ErrNoChange) {
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		log.Fatal("Error during migration:", err)
 	}
 
@@ -15,3 +15,15 @@ func NewErrorConflict(err error) error {
 func (e *ConflictError) Error() string {
 	return fmt.Sprintf("%v : %v", e.Err, "already exists")
 }
+
+type NotFoundError struct {
+	Err error
+}
+
+func NewErrorNotFound(err error) error {
+	return &NotFoundError{Err: err}
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%v : %v", e.Err, "not found")
+}
@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/RecoBattle/internal/app/adminapp"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAdminStore struct {
+	mock.Mock
+}
+
+func (m *MockAdminStore) GetUsers(ctx context.Context) ([]adminapp.User, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]adminapp.User), args.Error(1)
+}
+
+func (m *MockAdminStore) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	args := m.Called(ctx, userID, disabled)
+	return args.Error(0)
+}
+
+func (m *MockAdminStore) SetUserRole(ctx context.Context, userID, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockAdminStore) GetJobs(ctx context.Context, filter adminapp.JobFilter) ([]adminapp.Job, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]adminapp.Job), args.Error(1)
+}
+
+func (m *MockAdminStore) SetJobStatus(ctx context.Context, jobUUID, status string) error {
+	args := m.Called(ctx, jobUUID, status)
+	return args.Error(0)
+}
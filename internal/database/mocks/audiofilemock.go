@@ -2,12 +2,17 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/RecoBattle/internal/app/audiofilesapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
 
+// JobUUID подставляется мок-хранилищем вместо случайного UUID создаваемых заданий
+var JobUUID = uuid.MustParse("2d53b244-8844-40a6-ab37-e5b89019af0a")
+
 type MockAudioFileStore struct {
 	mock.Mock
 }
@@ -24,23 +29,77 @@ func (m *MockAudioFileStore) CreateASR(ctx context.Context, audioFile audiofiles
 	return args.Error(0)
 }
 
-func (m *MockAudioFileStore) UpdateStatusASR(ctx context.Context, audioFileUUID, status string) error {
-	args := m.Called(ctx, audioFileUUID, status)
+func (m *MockAudioFileStore) CreateASRs(ctx context.Context, jobs []audiofilesapp.AudioFile) ([]audiofilesapp.AudioFile, error) {
+	normalized := make([]audiofilesapp.AudioFile, 0, len(jobs))
+	for _, job := range jobs {
+		job.UUID = JobUUID
+		normalized = append(normalized, job)
+	}
+	args := m.Called(ctx, normalized)
+	return args.Get(0).([]audiofilesapp.AudioFile), args.Error(1)
+}
+
+func (m *MockAudioFileStore) GetFile(ctx context.Context, scope workspaceapp.Scope, fileID string) (*audiofilesapp.AudioFile, error) {
+	args := m.Called(ctx, scope, fileID)
+	return args.Get(0).(*audiofilesapp.AudioFile), args.Error(1)
+}
+
+func (m *MockAudioFileStore) ClaimASR(ctx context.Context, instance string) (*audiofilesapp.AudioFile, error) {
+	args := m.Called(ctx, instance)
+	return args.Get(0).(*audiofilesapp.AudioFile), args.Error(1)
+}
+
+func (m *MockAudioFileStore) HeartbeatASR(ctx context.Context, audioFileUUID, claimID string) error {
+	args := m.Called(ctx, audioFileUUID, claimID)
+	return args.Error(0)
+}
+
+func (m *MockAudioFileStore) ReleaseStaleASR(ctx context.Context, staleBefore time.Time) (int64, error) {
+	args := m.Called(ctx, staleBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAudioFileStore) ResumeASR(ctx context.Context, instance string) (int64, error) {
+	args := m.Called(ctx, instance)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAudioFileStore) RetryASR(ctx context.Context, audioFileUUID, claimID, lastError string, upstream time.Duration, nextAttemptAt time.Time) error {
+	args := m.Called(ctx, audioFileUUID, claimID, lastError, upstream, nextAttemptAt)
+	return args.Error(0)
+}
+
+func (m *MockAudioFileStore) FailASR(ctx context.Context, audioFileUUID, claimID, status, lastError string, upstream time.Duration) error {
+	args := m.Called(ctx, audioFileUUID, claimID, status, lastError, upstream)
+	return args.Error(0)
+}
+
+func (m *MockAudioFileStore) RequeueASR(ctx context.Context, scope workspaceapp.Scope, audioFileUUID string) error {
+	args := m.Called(ctx, scope, audioFileUUID)
 	return args.Error(0)
 }
 
-func (m *MockAudioFileStore) CreateResultASR(ctx context.Context, resultASR audiofilesapp.ResultASR) error {
-	args := m.Called(ctx, resultASR)
+func (m *MockAudioFileStore) SaveResultASR(ctx context.Context, audioFileUUID, claimID string, resultASR []audiofilesapp.ResultASR, upstream time.Duration) error {
+	args := m.Called(ctx, audioFileUUID, claimID, resultASR, upstream)
 	return args.Error(0)
 }
 
-func (m *MockAudioFileStore) GetAudioFiles(ctx context.Context, userID string) (*[]audiofilesapp.AudioFile, error) {
-	args := m.Called(ctx, userID)
+func (m *MockAudioFileStore) GetAudioFiles(ctx context.Context, scope workspaceapp.Scope) (*[]audiofilesapp.AudioFile, error) {
+	args := m.Called(ctx, scope)
 	return args.Get(0).(*[]audiofilesapp.AudioFile), args.Error(1)
 }
 
+func (m *MockAudioFileStore) GetASR(ctx context.Context, uuid string) (*audiofilesapp.AudioFile, error) {
+	args := m.Called(ctx, uuid)
+	return args.Get(0).(*audiofilesapp.AudioFile), args.Error(1)
+}
+
 func (m *MockAudioFileStore) GetResultASR(ctx context.Context, uuid string) (*[]audiofilesapp.ResultASR, error) {
-	id := "2d53b244-8844-40a6-ab37-e5b89019af0a"
-	args := m.Called(ctx, id)
+	args := m.Called(ctx, uuid)
 	return args.Get(0).(*[]audiofilesapp.ResultASR), args.Error(1)
 }
+
+func (m *MockAudioFileStore) GetUsage(ctx context.Context, userID string, since time.Time) ([]audiofilesapp.Usage, error) {
+	args := m.Called(ctx, userID, since)
+	return args.Get(0).([]audiofilesapp.Usage), args.Error(1)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/RecoBattle/internal/app/qualitycontrolapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
@@ -12,13 +13,23 @@ type MockQualityControlStore struct {
 	mock.Mock
 }
 
+func (m *MockQualityControlStore) GetFileOwner(ctx context.Context, fileID string) (string, string, error) {
+	args := m.Called(ctx, fileID)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
 func (m *MockQualityControlStore) Create(ctx context.Context, qualityControl qualitycontrolapp.IdealText) error {
 	qualityControl.UUID = uuid.MustParse("2d53b244-8844-40a6-ab37-e5b89019af0a")
 	args := m.Called(ctx, qualityControl)
 	return args.Error(0)
 }
 
-func (m *MockQualityControlStore) GetTextASRIdeal(ctx context.Context, fileID string) ([]qualitycontrolapp.QualityControl, string, error) {
+func (m *MockQualityControlStore) GetTextASRIdeal(ctx context.Context, fileID string) ([]qualitycontrolapp.QualityControl, map[string]string, error) {
 	args := m.Called(ctx, fileID)
-	return args.Get(0).([]qualitycontrolapp.QualityControl), args.Get(1).(string), args.Error(2)
+	return args.Get(0).([]qualitycontrolapp.QualityControl), args.Get(1).(map[string]string), args.Error(2)
+}
+
+func (m *MockQualityControlStore) GetFileTexts(ctx context.Context, scope workspaceapp.Scope, fileIDs []string) ([]qualitycontrolapp.FileTexts, error) {
+	args := m.Called(ctx, scope, fileIDs)
+	return args.Get(0).([]qualitycontrolapp.FileTexts), args.Error(1)
 }
@@ -23,3 +23,43 @@ func (m *MockUserStore) GetUser(ctx context.Context, condition map[string]string
 	args := m.Called(ctx, condition)
 	return args.Get(0).(*userapp.User), args.Error(1)
 }
+
+func (m *MockUserStore) UpdatePassword(ctx context.Context, userID, hash string) error {
+	args := m.Called(ctx, userID, hash)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) CreateRefreshToken(ctx context.Context, token userapp.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) UseRefreshToken(ctx context.Context, jti string) (*userapp.RefreshToken, error) {
+	args := m.Called(ctx, jti)
+	return args.Get(0).(*userapp.RefreshToken), args.Error(1)
+}
+
+func (m *MockUserStore) RevokeRefreshTokens(ctx context.Context, userID, familyID string) error {
+	args := m.Called(ctx, userID, familyID)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) CreateAPIKey(ctx context.Context, apiKey userapp.APIKey) error {
+	args := m.Called(ctx, apiKey)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) GetAPIKeys(ctx context.Context, userID string) ([]userapp.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]userapp.APIKey), args.Error(1)
+}
+
+func (m *MockUserStore) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	args := m.Called(ctx, userID, keyID)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) UseAPIKey(ctx context.Context, hash string) (*userapp.APIKey, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(*userapp.APIKey), args.Error(1)
+}
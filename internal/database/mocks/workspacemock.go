@@ -0,0 +1,42 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWorkspaceStore struct {
+	mock.Mock
+}
+
+func (m *MockWorkspaceStore) CreateWorkspace(ctx context.Context, workspace workspaceapp.Workspace) error {
+	args := m.Called(ctx, workspace)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceStore) GetWorkspaces(ctx context.Context, userID string) ([]workspaceapp.Workspace, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]workspaceapp.Workspace), args.Error(1)
+}
+
+func (m *MockWorkspaceStore) GetMemberRole(ctx context.Context, workspaceID, userID string) (string, error) {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockWorkspaceStore) GetMembers(ctx context.Context, workspaceID string) ([]workspaceapp.Member, error) {
+	args := m.Called(ctx, workspaceID)
+	return args.Get(0).([]workspaceapp.Member), args.Error(1)
+}
+
+func (m *MockWorkspaceStore) SetMember(ctx context.Context, workspaceID, login, role string) (*workspaceapp.Member, error) {
+	args := m.Called(ctx, workspaceID, login, role)
+	return args.Get(0).(*workspaceapp.Member), args.Error(1)
+}
+
+func (m *MockWorkspaceStore) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.Error(0)
+}
@@ -6,8 +6,11 @@ import (
 	"errors"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/RecoBattle/internal/app/audiofilesapp"
 	"github.com/RecoBattle/internal/app/qualitycontrolapp"
+	"github.com/RecoBattle/internal/app/workspaceapp"
 	"github.com/RecoBattle/internal/database"
+	"github.com/RecoBattle/internal/database/workspacedb"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -23,9 +26,47 @@ func NewQCStore(db *sql.DB) *QualityControlStore {
 	return &QualityControlStore{db: db}
 }
 
+// GetFileOwner загрузивший файл пользователь и рабочее пространство файла, пустое для личного файла.
+// Принадлежность пространству пользователя проверяет приложение
+func (d *QualityControlStore) GetFileOwner(ctx context.Context, fileID string) (string, string, error) {
+
+	var userID, workspaceID string
+
+	err := d.db.QueryRowContext(ctx, "SELECT user_id, COALESCE(workspace_id, '') FROM audiofiles WHERE file_id=$1", fileID).Scan(&userID, &workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", database.NewErrorNotFound(errors.New(fileID))
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return userID, workspaceID, nil
+}
+
+// Create вставляет эталонный текст канала файла
 func (d *QualityControlStore) Create(ctx context.Context, it qualitycontrolapp.IdealText) error {
 
-	_, err := d.db.ExecContext(ctx, "INSERT INTO quality_control (uuid, file_id, channel_tag, text) VALUES($1,$2,$3,$4)", it.UUID.String(), it.FileID, it.ChannelTag, it.Text)
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	file := squirrel.Select().
+		Column("?", it.UUID.String()).
+		Column("file_id").
+		Column("?", it.ChannelTag).
+		Column("?", it.Text).
+		From("audiofiles").
+		Where(squirrel.Eq{"file_id": it.FileID})
+
+	query, args, err := qb.Insert("quality_control").
+		Columns("uuid", "file_id", "channel_tag", "text").
+		Select(file).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	res, err := d.db.ExecContext(ctx, query, args...)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -36,60 +77,187 @@ func (d *QualityControlStore) Create(ctx context.Context, it qualitycontrolapp.I
 		return err
 	}
 
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return database.NewErrorNotFound(errors.New(it.FileID))
+	}
+
 	return nil
 }
 
-func (d *QualityControlStore) GetTextASRIdeal(ctx context.Context, fileID string) ([]qualitycontrolapp.QualityControl, string, error) {
+func (d *QualityControlStore) GetTextASRIdeal(ctx context.Context, fileID string) ([]qualitycontrolapp.QualityControl, map[string]string, error) {
 
 	var rows *sql.Rows
 	var qcs []qualitycontrolapp.QualityControl
 
 	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 
-	query, args, err := qb.Select("text").
+	rows, err := qb.Select("channel_tag", "text").
 		From("quality_control").
 		Where(squirrel.Eq{"file_id": fileID}).
-		ToSql()
+		RunWith(d.db).
+		QueryContext(ctx)
 
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	row := d.db.QueryRowContext(ctx, query, args...)
+	defer rows.Close()
 
-	if row.Err() != nil {
-		return nil, "", row.Err()
+	idealTexts := make(map[string]string)
+
+	for rows.Next() {
+		var channelTag, text string
+		if err = rows.Scan(&channelTag, &text); err != nil {
+			return nil, nil, err
+		}
+		idealTexts[channelTag] = text
 	}
 
-	var idealText string
-	if err = row.Scan(&idealText); err != nil {
-		return qcs, "", nil
+	if rows.Err() != nil {
+		return nil, nil, rows.Err()
 	}
 
-	rows, err = qb.Select("asr.asr", "res.text").
-		From("asr").
+	if len(idealTexts) == 0 {
+		return qcs, idealTexts, nil
+	}
+
+	rows, err = qb.Select("asr.uuid", "asr.asr", "res.channel_tag", "string_agg(res.text, ' ' ORDER BY res.start_time)").
+		FromSelect(latestJobs([]string{fileID}), "asr").
 		InnerJoin("result_asr res ON asr.uuid = res.uuid").
-		Where(squirrel.Eq{"file_id": fileID}).
+		GroupBy("asr.uuid", "asr.asr", "res.channel_tag").
+		OrderBy("asr.asr", "res.channel_tag").
 		RunWith(d.db).
-		Query()
+		QueryContext(ctx)
 
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	if rows.Err() != nil {
-		return nil, "", rows.Err()
+		return nil, nil, rows.Err()
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
 		var qc qualitycontrolapp.QualityControl
-		if err = rows.Scan(&qc.ASR, &qc.TextASR); err != nil {
-			return nil, "", err
+		if err = rows.Scan(&qc.JobUUID, &qc.ASR, &qc.ChannelTag, &qc.TextASR); err != nil {
+			return nil, nil, err
 		}
 		qcs = append(qcs, qc)
 	}
 
-	return qcs, idealText, nil
+	return qcs, idealTexts, nil
+}
+
+// GetFileTexts эталонные тексты и результаты ASR файлов пространства, у которых есть эталон; пустой fileIDs — всех таких файлов
+func (d *QualityControlStore) GetFileTexts(ctx context.Context, scope workspaceapp.Scope, fileIDs []string) ([]qualitycontrolapp.FileTexts, error) {
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	builder := qb.Select("q.file_id", "f.duration", "q.channel_tag", "q.text").
+		From("quality_control q").
+		InnerJoin("audiofiles f ON q.file_id = f.file_id").
+		Where(workspacedb.ScopeCondition("f.", scope)).
+		OrderBy("q.file_id")
+
+	if len(fileIDs) > 0 {
+		builder = builder.Where(squirrel.Eq{"q.file_id": fileIDs})
+	}
+
+	rows, err := builder.RunWith(d.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var files []qualitycontrolapp.FileTexts
+
+	index := make(map[string]int)
+
+	for rows.Next() {
+
+		var (
+			fileID, channelTag, text string
+			duration                 float64
+		)
+
+		if err = rows.Scan(&fileID, &duration, &channelTag, &text); err != nil {
+			return nil, err
+		}
+
+		i, ok := index[fileID]
+		if !ok {
+			i = len(files)
+			index[fileID] = i
+			files = append(files, qualitycontrolapp.FileTexts{FileID: fileID, Duration: duration, IdealTexts: make(map[string]string)})
+		}
+
+		files[i].IdealTexts[channelTag] = text
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return files, nil
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.FileID)
+	}
+
+	results, err := qb.Select("asr.file_id", "asr.uuid", "asr.asr", "res.channel_tag", "string_agg(res.text, ' ' ORDER BY res.start_time)").
+		FromSelect(latestJobs(ids), "asr").
+		InnerJoin("result_asr res ON asr.uuid = res.uuid").
+		GroupBy("asr.uuid", "asr.file_id", "asr.asr", "res.channel_tag").
+		OrderBy("asr.file_id", "asr.asr", "res.channel_tag").
+		RunWith(d.db).
+		QueryContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer results.Close()
+
+	for results.Next() {
+
+		var (
+			fileID string
+			qc     qualitycontrolapp.QualityControl
+		)
+
+		if err = results.Scan(&fileID, &qc.JobUUID, &qc.ASR, &qc.ChannelTag, &qc.TextASR); err != nil {
+			return nil, err
+		}
+
+		i := index[fileID]
+		files[i].Results = append(files[i].Results, qc)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// latestJobs последнее успешно распознанное задание каждого ASR по каждому из файлов: файл может быть распознан
+// одним ASR несколько раз, например после перезапуска упавшего задания
+func latestJobs(fileIDs []string) squirrel.SelectBuilder {
+
+	return squirrel.Select("uuid", "file_id", "asr").
+		Options("DISTINCT ON (file_id, asr)").
+		From("asr").
+		Where(squirrel.Eq{"file_id": fileIDs, "status": audiofilesapp.StatusPROCESSED}).
+		OrderBy("file_id", "asr", "finished_at DESC NULLS LAST", "created_at DESC")
 }
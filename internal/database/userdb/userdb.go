@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/RecoBattle/internal/app/userapp"
@@ -27,7 +28,7 @@ func NewUserStore(db *sql.DB) *UserStore {
 
 func (d *UserStore) Create(ctx context.Context, user userapp.User) error {
 
-	_, err := d.db.ExecContext(ctx, "INSERT INTO users (uuid, login, hash_pass) VALUES($1,$2,$3)", user.UUID.String(), user.Username, user.Password)
+	_, err := d.db.ExecContext(ctx, "INSERT INTO users (uuid, login, hash_pass, role) VALUES($1,$2,$3,$4)", user.UUID.String(), user.Username, user.Password, user.Role)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -46,7 +47,7 @@ func (d *UserStore) GetUser(ctx context.Context, condition map[string]string) (*
 
 	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 
-	query, args, err := qb.Select("uuid, login, hash_pass").
+	query, args, err := qb.Select("uuid, login, hash_pass, role, disabled_at IS NOT NULL").
 		From("users").
 		Where(condition).
 		ToSql()
@@ -71,10 +72,210 @@ func (d *UserStore) GetUser(ctx context.Context, condition map[string]string) (*
 
 	for rows.Next() {
 
-		if err = rows.Scan(&user.UUID, &user.Username, &user.Password); err != nil {
+		if err = rows.Scan(&user.UUID, &user.Username, &user.Password, &user.Role, &user.Disabled); err != nil {
 			return nil, errors.New("401")
 		}
 	}
 
 	return &user, nil
 }
+
+func (d *UserStore) CreateRefreshToken(ctx context.Context, token userapp.RefreshToken) error {
+
+	_, err := d.db.ExecContext(ctx, "INSERT INTO refresh_tokens (jti, family_id, user_id, expires_at) VALUES($1,$2,$3,$4)",
+		token.JTI, token.FamilyID, token.UserID, token.ExpiresAt)
+
+	return err
+}
+
+// UseRefreshToken помечает токен использованным и возвращает его состояние до этого вызова.
+// Строка блокируется, чтобы два параллельных обмена одного токена не получили по новой паре
+func (d *UserStore) UseRefreshToken(ctx context.Context, jti string) (*userapp.RefreshToken, error) {
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	token := userapp.RefreshToken{JTI: jti}
+
+	var usedAt sql.NullTime
+
+	err = tx.QueryRowContext(ctx, `SELECT family_id, user_id, expires_at, used_at, revoked_at IS NOT NULL
+		FROM refresh_tokens WHERE jti=$1 FOR UPDATE`, jti).
+		Scan(&token.FamilyID, &token.UserID, &token.ExpiresAt, &usedAt, &token.Revoked)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.NewErrorNotFound(errors.New(jti))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token.Used, token.UsedAt = usedAt.Valid, usedAt.Time
+
+	if !token.Used && !token.Revoked {
+		if _, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at=now() WHERE jti=$1", jti); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (d *UserStore) RevokeRefreshTokens(ctx context.Context, userID, familyID string) error {
+
+	condition := squirrel.Eq{"user_id": userID}
+	if familyID != "" {
+		condition["family_id"] = familyID
+	}
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	query, args, err := qb.Update("refresh_tokens").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(condition).
+		Where("revoked_at IS NULL").
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+func (d *UserStore) UpdatePassword(ctx context.Context, userID, hash string) error {
+
+	_, err := d.db.ExecContext(ctx, "UPDATE users SET hash_pass=$1 WHERE uuid=$2", hash, userID)
+
+	return err
+}
+
+func (d *UserStore) CreateAPIKey(ctx context.Context, apiKey userapp.APIKey) error {
+
+	_, err := d.db.ExecContext(ctx, "INSERT INTO api_keys (uuid, user_id, name, prefix, key_hash, scopes, created_at) VALUES($1,$2,$3,$4,$5,$6,$7)",
+		apiKey.UUID.String(), apiKey.UserID, apiKey.Name, apiKey.Prefix, apiKey.Hash, strings.Join(apiKey.Scopes, ","), apiKey.CreatedAt)
+
+	return err
+}
+
+func (d *UserStore) GetAPIKeys(ctx context.Context, userID string) ([]userapp.APIKey, error) {
+
+	qb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	query, args, err := qb.Select("uuid", "name", "prefix", "scopes", "created_at", "last_used_at").
+		From("api_keys").
+		Where(squirrel.Eq{"user_id": userID}).
+		Where("revoked_at IS NULL").
+		OrderBy("created_at").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var apiKeys []userapp.APIKey
+
+	for rows.Next() {
+
+		apiKey := userapp.APIKey{UserID: userID}
+		if err = scanAPIKey(rows, &apiKey); err != nil {
+			return nil, err
+		}
+
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+func (d *UserStore) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+
+	res, err := d.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at=now() WHERE uuid=$1 AND user_id=$2 AND revoked_at IS NULL", keyID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return database.NewErrorNotFound(errors.New(keyID))
+	}
+
+	return nil
+}
+
+// UseAPIKey находит неотозванный ключ по хешу и отмечает время его использования.
+func (d *UserStore) UseAPIKey(ctx context.Context, hash string) (*userapp.APIKey, error) {
+
+	// ключи отключённых пользователей не действуют
+	row := d.db.QueryRowContext(ctx, `UPDATE api_keys SET last_used_at=now() WHERE key_hash=$1 AND revoked_at IS NULL
+		AND user_id IN (SELECT uuid FROM users WHERE disabled_at IS NULL)
+		RETURNING uuid, name, prefix, scopes, created_at, last_used_at, user_id`, hash)
+
+	apiKey := userapp.APIKey{Hash: hash}
+
+	err := scanAPIKey(row, &apiKey, &apiKey.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.NewErrorNotFound(errors.New(hash))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row scanner, apiKey *userapp.APIKey, extra ...any) error {
+
+	var (
+		scopes     string
+		lastUsedAt sql.NullTime
+	)
+
+	dest := append([]any{&apiKey.UUID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.CreatedAt, &lastUsedAt}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	apiKey.Scopes = []string{}
+	if scopes != "" {
+		apiKey.Scopes = strings.Split(scopes, ",")
+	}
+
+	if lastUsedAt.Valid {
+		apiKey.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return nil
+}
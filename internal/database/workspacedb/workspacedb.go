@@ -0,0 +1,174 @@
+package workspacedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/RecoBattle/internal/app/workspaceapp"
+	"github.com/RecoBattle/internal/database"
+)
+
+var _ workspaceapp.WorkspaceStore = &WorkspaceStore{}
+
+type WorkspaceStore struct {
+	db *sql.DB
+}
+
+func NewWorkspaceStore(db *sql.DB) *WorkspaceStore {
+
+	return &WorkspaceStore{db: db}
+}
+
+// ScopeCondition условие на строки audiofiles пространства. Личные файлы — файлы пользователя вне рабочих пространств.
+// prefix — алиас таблицы с точкой или пустая строка
+func ScopeCondition(prefix string, scope workspaceapp.Scope) squirrel.Sqlizer {
+
+	if scope.WorkspaceID != "" {
+		return squirrel.Eq{prefix + "workspace_id": scope.WorkspaceID}
+	}
+
+	return squirrel.Eq{prefix + "user_id": scope.UserID, prefix + "workspace_id": nil}
+}
+
+// NullWorkspace значение колонки workspace_id для личного пространства — NULL
+func NullWorkspace(workspaceID string) sql.NullString {
+	return sql.NullString{String: workspaceID, Valid: workspaceID != ""}
+}
+
+func (d *WorkspaceStore) CreateWorkspace(ctx context.Context, workspace workspaceapp.Workspace) error {
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "INSERT INTO workspaces (uuid, name, created_by, created_at) VALUES($1,$2,$3,$4)",
+		workspace.UUID.String(), workspace.Name, workspace.CreatedBy, workspace.CreatedAt); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "INSERT INTO workspace_members (workspace_id, user_id, role) VALUES($1,$2,$3)",
+		workspace.UUID.String(), workspace.CreatedBy, workspaceapp.RoleOwner); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *WorkspaceStore) GetWorkspaces(ctx context.Context, userID string) ([]workspaceapp.Workspace, error) {
+
+	rows, err := d.db.QueryContext(ctx, `SELECT w.uuid, w.name, m.role, w.created_at, w.created_by FROM workspaces w
+		INNER JOIN workspace_members m ON m.workspace_id = w.uuid WHERE m.user_id=$1 ORDER BY w.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var workspaces []workspaceapp.Workspace
+
+	for rows.Next() {
+
+		var workspace workspaceapp.Workspace
+		if err = rows.Scan(&workspace.UUID, &workspace.Name, &workspace.Role, &workspace.CreatedAt, &workspace.CreatedBy); err != nil {
+			return nil, err
+		}
+
+		workspaces = append(workspaces, workspace)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return workspaces, nil
+}
+
+func (d *WorkspaceStore) GetMemberRole(ctx context.Context, workspaceID, userID string) (string, error) {
+
+	var role string
+
+	err := d.db.QueryRowContext(ctx, "SELECT role FROM workspace_members WHERE workspace_id=$1 AND user_id=$2", workspaceID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", database.NewErrorNotFound(errors.New(workspaceID))
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+func (d *WorkspaceStore) GetMembers(ctx context.Context, workspaceID string) ([]workspaceapp.Member, error) {
+
+	rows, err := d.db.QueryContext(ctx, `SELECT m.user_id, u.login, m.role FROM workspace_members m
+		INNER JOIN users u ON u.uuid = m.user_id WHERE m.workspace_id=$1 ORDER BY u.login`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var members []workspaceapp.Member
+
+	for rows.Next() {
+
+		var member workspaceapp.Member
+		if err = rows.Scan(&member.UserID, &member.Login, &member.Role); err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetMember добавляет пользователя с логином login или меняет роль уже состоящего в пространстве. Неизвестный логин — NotFoundError
+func (d *WorkspaceStore) SetMember(ctx context.Context, workspaceID, login, role string) (*workspaceapp.Member, error) {
+
+	member := workspaceapp.Member{Login: login, Role: role}
+
+	err := d.db.QueryRowContext(ctx, `INSERT INTO workspace_members (workspace_id, user_id, role)
+		SELECT $1, uuid, $3 FROM users WHERE login=$2
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role=EXCLUDED.role
+		RETURNING user_id`, workspaceID, login, role).Scan(&member.UserID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.NewErrorNotFound(errors.New(login))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (d *WorkspaceStore) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+
+	res, err := d.db.ExecContext(ctx, "DELETE FROM workspace_members WHERE workspace_id=$1 AND user_id=$2", workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return database.NewErrorNotFound(errors.New(userID))
+	}
+
+	return nil
+}
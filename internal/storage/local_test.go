@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RecoBattle/cmd/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage(t *testing.T) {
+
+	ctx := context.Background()
+	root := t.TempDir()
+
+	s, err := NewLocalStorage(filepath.Join(root, "files"))
+	require.NoError(t, err)
+
+	t.Run("Put, Get and Delete", func(t *testing.T) {
+
+		require.NoError(t, s.Put(ctx, "abc.wav", strings.NewReader("data"), 4))
+
+		file, err := s.Get(ctx, "abc.wav")
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+		assert.Equal(t, "data", string(data))
+
+		require.NoError(t, s.Put(ctx, "abc.wav", strings.NewReader("new"), 3))
+		file, err = s.Get(ctx, "abc.wav")
+		require.NoError(t, err)
+		data, _ = io.ReadAll(file)
+		file.Close()
+		assert.Equal(t, "new", string(data))
+
+		object, err := s.Open(ctx, "abc.wav")
+		require.NoError(t, err)
+		_, err = object.Seek(1, io.SeekStart)
+		require.NoError(t, err)
+		data, _ = io.ReadAll(object)
+		object.Close()
+		assert.Equal(t, "ew", string(data))
+
+		require.NoError(t, s.Delete(ctx, "abc.wav"))
+		_, err = s.Get(ctx, "abc.wav")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		entries, err := os.ReadDir(filepath.Join(root, "files"))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+
+		_, err := s.Get(ctx, "missing.wav")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = s.Open(ctx, "missing.wav")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoError(t, s.Delete(ctx, "missing.wav"))
+	})
+
+	t.Run("Invalid keys", func(t *testing.T) {
+
+		for _, key := range []string{"", ".", "..", "../secret.wav", "dir/file.wav", `dir\file.wav`, "a\x00b"} {
+			err := s.Put(ctx, key, strings.NewReader("data"), 4)
+			assert.True(t, errors.Is(err, ErrInvalidKey), key)
+
+			_, err = s.Get(ctx, key)
+			assert.True(t, errors.Is(err, ErrInvalidKey), key)
+
+			assert.True(t, errors.Is(s.Delete(ctx, key), ErrInvalidKey), key)
+		}
+
+		_, err := os.Stat(filepath.Join(root, "secret.wav"))
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	})
+}
+
+func TestNewStorage(t *testing.T) {
+
+	s, err := NewStorage(TypeLocal, t.TempDir(), config.S3Storage{})
+	require.NoError(t, err)
+	assert.IsType(t, &LocalStorage{}, s)
+
+	s, err = NewStorage(TypeS3, "", config.S3Storage{Endpoint: "http://localhost:9000", Bucket: "recobattle"})
+	require.NoError(t, err)
+	assert.IsType(t, &S3Storage{}, s)
+
+	_, err = NewStorage(TypeS3, "", config.S3Storage{Endpoint: "http://localhost:9000"})
+	assert.Error(t, err)
+
+	_, err = NewStorage("ftp", "", config.S3Storage{})
+	assert.Error(t, err)
+}
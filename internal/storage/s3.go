@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RecoBattle/cmd/config"
+)
+
+const (
+	defaultRegion  = "us-east-1"
+	unsignedBody   = "UNSIGNED-PAYLOAD"
+	emptyBodyHash  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	signAlgorithm  = "AWS4-HMAC-SHA256"
+	amzDateLayout  = "20060102T150405Z"
+	errBodyMaxSize = 1024
+)
+
+var _ Storage = &S3Storage{}
+
+// S3Storage хранит объекты в S3-совместимом бакете (AWS S3, MinIO), запросы подписываются AWS Signature V4
+type S3Storage struct {
+	cnf      config.S3Storage
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3Storage(cnf config.S3Storage) (*S3Storage, error) {
+
+	endpoint, err := url.Parse(cnf.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cnf.Endpoint)
+	}
+
+	if cnf.Bucket == "" {
+		return nil, errors.New("S3 bucket is not set")
+	}
+
+	if cnf.Region == "" {
+		cnf.Region = defaultRegion
+	}
+
+	return &S3Storage{
+		cnf:      cnf,
+		endpoint: endpoint,
+		client:   &http.Client{},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "audio/wav")
+
+	response, err := s.do(req, unsignedBody)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.do(req, emptyBodyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// Open возвращает объект с произвольным доступом: размер берётся из HEAD, данные читаются Range-запросами с текущей позиции
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.do(req, emptyBodyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Body.Close()
+
+	if response.ContentLength < 0 {
+		return nil, fmt.Errorf("S3 HEAD %s returned no Content-Length", req.URL.Path)
+	}
+
+	return &s3Object{storage: s, ctx: ctx, key: key, size: response.ContentLength}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := s.do(req, emptyBodyHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+// newRequest строит URL объекта: path-style (endpoint/bucket/key) для MinIO или virtual-hosted (bucket.endpoint/key) для AWS
+func (s *S3Storage) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	u := *s.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+
+	if s.cnf.PathStyle {
+		path += "/" + s.cnf.Bucket + "/" + key
+	} else {
+		u.Host = s.cnf.Bucket + "." + u.Host
+		path += "/" + key
+	}
+
+	u.Path = path
+	u.RawPath = escapePath(path)
+
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+func (s *S3Storage) do(req *http.Request, payloadHash string) (*http.Response, error) {
+
+	s.sign(req, payloadHash, s.now())
+
+	response, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response, nil
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(response.Body, errBodyMaxSize))
+
+	return nil, fmt.Errorf("S3 %s %s returned status %d: %s", req.Method, req.URL.Path, response.StatusCode, message)
+}
+
+// sign добавляет в запрос заголовки x-amz-date, x-amz-content-sha256 и Authorization по AWS Signature V4
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+
+	amzDate := now.UTC().Format(amzDateLayout)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cnf.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{signAlgorithm, amzDate, scope, hex.EncodeToString(canonicalHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cnf.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cnf.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, s.cnf.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(data))
+
+	return hash.Sum(nil)
+}
+
+// escapePath кодирует путь так, как этого требует SigV4: всё, кроме A-Z a-z 0-9 - _ . ~ и разделителя /
+func escapePath(path string) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+type s3Object struct {
+	storage *S3Storage
+	ctx     context.Context
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+
+		req, err := o.storage.newRequest(o.ctx, http.MethodGet, o.key, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+
+		response, err := o.storage.do(req, emptyBodyHash)
+		if err != nil {
+			return 0, err
+		}
+
+		if response.StatusCode != http.StatusPartialContent && o.offset > 0 {
+			response.Body.Close()
+			return 0, fmt.Errorf("S3 GET %s ignored Range header, status %d", req.URL.Path, response.StatusCode)
+		}
+
+		o.body = response.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+
+	return n, err
+}
+
+// Seek только меняет позицию, новый GET будет отправлен при следующем чтении
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seek before the start of the object")
+	}
+
+	if offset != o.offset {
+		o.Close()
+		o.offset = offset
+	}
+
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+
+	if o.body == nil {
+		return nil
+	}
+
+	err := o.body.Close()
+	o.body = nil
+
+	return err
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RecoBattle/cmd/config"
+)
+
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// Storage хранилище аудио-файлов. Ключ — плоское имя объекта без каталогов
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStorage создаёт хранилище выбранного типа: локальный каталог pathFileStorage или S3-совместимый бакет
+func NewStorage(storageType, pathFileStorage string, cnf config.S3Storage) (Storage, error) {
+
+	switch storageType {
+	case TypeLocal, "":
+		return NewLocalStorage(pathFileStorage)
+	case TypeS3:
+		return NewS3Storage(cnf)
+	}
+
+	return nil, fmt.Errorf("unknown storage type %q", storageType)
+}
+
+// validateKey не даёт выйти за пределы хранилища через ключ вида ../file или dir/file
+func validateKey(key string) error {
+
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) || strings.ContainsRune(key, 0) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+
+	return nil
+}